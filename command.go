@@ -0,0 +1,18 @@
+package main
+
+import (
+	"backend/config"
+	"fmt"
+)
+
+// runCommand runs the subcommand named by the first argument instead of the server
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "seed":
+		return runSeed(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, use migrate or seed", args[0])
+	}
+}
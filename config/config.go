@@ -0,0 +1,237 @@
+// Package config loads the typed application configuration from defaults for the active
+// profile, the .env file, an optional YAML or TOML file and environment variables
+package config
+
+import "time"
+
+// Profiles select the defaults and how strictly the configuration is validated
+const (
+	ProfileDev  = "dev"
+	ProfileTest = "test"
+	ProfileProd = "prod"
+)
+
+// Config is the whole application configuration
+type Config struct {
+	Profile  string         `yaml:"-" toml:"-" env:"APP_ENV"`
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Mail     MailConfig     `yaml:"mail" toml:"mail"`
+}
+
+// ServerConfig controls the HTTP server
+type ServerConfig struct {
+	Addr            string        `yaml:"addr" toml:"addr" env:"SERVER_ADDR"`                              // Address the server listens on
+	PublicURL       string        `yaml:"public_url" toml:"public_url" env:"PUBLIC_URL"`                   // Base URL of the links sent in emails
+	ResetURL        string        `yaml:"reset_url" toml:"reset_url" env:"PASSWORD_RESET_URL"`             // Frontend page asking for the new password, PUBLIC_URL/reset-password by default
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"` // Time given to in-flight requests and workers on shutdown
+}
+
+// DatabaseConfig is the database connection. For sqlite, Name is the path of the database file.
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver" toml:"driver" env:"DB_DRIVER"` // postgres, mysql or sqlite
+	Host     string `yaml:"host" toml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER"`
+	Password string `yaml:"password" toml:"password" env:"DB_PASSWORD"`
+	Name     string `yaml:"name" toml:"name" env:"DB_NAME"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE"`
+
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate" env:"DB_AUTO_MIGRATE"` // Apply pending migrations at startup
+
+	Pool    PoolConfig    `yaml:"pool" toml:"pool"`
+	Connect ConnectConfig `yaml:"connect" toml:"connect"`
+}
+
+// PoolConfig sizes the connection pool. SQLite always uses a single open connection.
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"` // 0 is unlimited
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`    // 0 keeps connections forever
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"` // 0 keeps idle connections forever
+}
+
+// ConnectConfig retries the first connection while the database is still starting,
+// doubling the delay after every failed attempt
+type ConnectConfig struct {
+	Attempts   int           `yaml:"attempts" toml:"attempts" env:"DB_CONNECT_ATTEMPTS"`
+	Backoff    time.Duration `yaml:"backoff" toml:"backoff" env:"DB_CONNECT_BACKOFF"`             // Delay after the first failure
+	MaxBackoff time.Duration `yaml:"max_backoff" toml:"max_backoff" env:"DB_CONNECT_MAX_BACKOFF"` // Longest delay between attempts
+}
+
+// MailConfig is the SMTP server emails are sent through
+type MailConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"MAIL_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"MAIL_PORT"`
+	Username string `yaml:"username" toml:"username" env:"MAIL_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"MAIL_PASSWORD"`
+	From     string `yaml:"from" toml:"from" env:"MAIL_FROM_ADDRESS"`
+}
+
+// AuthConfig covers tokens, logins and account security
+type AuthConfig struct {
+	JWT               JWTConfig               `yaml:"jwt" toml:"jwt"`
+	AccessTokenTTL    time.Duration           `yaml:"access_token_ttl" toml:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL   time.Duration           `yaml:"refresh_token_ttl" toml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+	TokenStore        string                  `yaml:"token_store" toml:"token_store" env:"TOKEN_STORE"` // database (postgres is an alias) or memory
+	AdminEmail        string                  `yaml:"admin_email" toml:"admin_email" env:"ADMIN_EMAIL"` // Granted the admin role at startup
+	TOTPIssuer        string                  `yaml:"totp_issuer" toml:"totp_issuer" env:"TOTP_ISSUER"` // Shown in authenticator apps
+	LoginThrottle     LoginThrottleConfig     `yaml:"login_throttle" toml:"login_throttle"`
+	PasswordPolicy    PasswordPolicyConfig    `yaml:"password_policy" toml:"password_policy"`
+	Argon2            Argon2Config            `yaml:"argon2" toml:"argon2"`
+	EmailVerification EmailVerificationConfig `yaml:"email_verification" toml:"email_verification"`
+	Session           SessionConfig           `yaml:"session" toml:"session"`
+	OIDC              []OIDCProviderConfig    `yaml:"oidc" toml:"oidc"`
+}
+
+// JWTConfig selects the token signing keys, either JWT_KEYS or a single HS256 JWT_SECRET
+type JWTConfig struct {
+	Secret    string   `yaml:"secret" toml:"secret" env:"JWT_SECRET"`
+	Keys      []string `yaml:"keys" toml:"keys" env:"JWT_KEYS"` // kid:alg:path entries, public keys only verify
+	ActiveKID string   `yaml:"active_kid" toml:"active_kid" env:"JWT_ACTIVE_KID"`
+}
+
+// LoginThrottleConfig controls how failed logins are delayed and locked out
+type LoginThrottleConfig struct {
+	MaxAccountFailures int           `yaml:"max_attempts" toml:"max_attempts" env:"LOGIN_MAX_ATTEMPTS"`          // Failures before an account is locked
+	MaxIPFailures      int           `yaml:"ip_max_attempts" toml:"ip_max_attempts" env:"LOGIN_IP_MAX_ATTEMPTS"` // Failures before a client IP is locked
+	LockoutDuration    time.Duration `yaml:"lockout_duration" toml:"lockout_duration" env:"LOGIN_LOCKOUT_DURATION"`
+	BaseDelay          time.Duration `yaml:"base_delay" toml:"base_delay" env:"LOGIN_BASE_DELAY"` // Doubled after each further failure
+	MaxDelay           time.Duration `yaml:"max_delay" toml:"max_delay" env:"LOGIN_MAX_DELAY"`
+}
+
+// PasswordPolicyConfig is the set of rules new passwords must follow
+type PasswordPolicyConfig struct {
+	MinLength     int    `yaml:"min_length" toml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	MaxLength     int    `yaml:"max_length" toml:"max_length" env:"PASSWORD_MAX_LENGTH"`
+	RequireUpper  bool   `yaml:"require_upper" toml:"require_upper" env:"PASSWORD_REQUIRE_UPPER"`
+	RequireLower  bool   `yaml:"require_lower" toml:"require_lower" env:"PASSWORD_REQUIRE_LOWER"`
+	RequireDigit  bool   `yaml:"require_digit" toml:"require_digit" env:"PASSWORD_REQUIRE_DIGIT"`
+	RequireSymbol bool   `yaml:"require_symbol" toml:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL"`
+	HistorySize   int    `yaml:"history" toml:"history" env:"PASSWORD_HISTORY"`
+	DenylistFile  string `yaml:"denylist_file" toml:"denylist_file" env:"PASSWORD_DENYLIST_FILE"`
+}
+
+// Argon2Config is the cost of new password hashes
+type Argon2Config struct {
+	Memory  uint32 `yaml:"memory" toml:"memory" env:"ARGON2_MEMORY"` // KiB
+	Time    uint32 `yaml:"time" toml:"time" env:"ARGON2_TIME"`
+	Threads uint8  `yaml:"threads" toml:"threads" env:"ARGON2_THREADS"`
+}
+
+// EmailVerificationConfig controls how email addresses are verified
+type EmailVerificationConfig struct {
+	Required              bool          `yaml:"required" toml:"required" env:"EMAIL_VERIFICATION_REQUIRED"`
+	TokenTTL              time.Duration `yaml:"ttl" toml:"ttl" env:"EMAIL_VERIFICATION_TTL"`
+	ResendInterval        time.Duration `yaml:"resend_interval" toml:"resend_interval" env:"EMAIL_VERIFICATION_RESEND_INTERVAL"`
+	UnverifiedAccountDays int           `yaml:"unverified_account_days" toml:"unverified_account_days" env:"UNVERIFIED_ACCOUNT_DAYS"` // Zero keeps unverified accounts, as does Required false
+	CleanupInterval       time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval" env:"UNVERIFIED_CLEANUP_INTERVAL"`
+}
+
+// SessionConfig controls the cookie sessions used by server-rendered pages
+type SessionConfig struct {
+	TTL            time.Duration `yaml:"ttl" toml:"ttl" env:"SESSION_TTL"`
+	CookieSecure   bool          `yaml:"cookie_secure" toml:"cookie_secure" env:"SESSION_COOKIE_SECURE"`
+	CookieSameSite string        `yaml:"cookie_samesite" toml:"cookie_samesite" env:"SESSION_COOKIE_SAMESITE"` // lax or strict
+}
+
+// OIDCProviderConfig is an OpenID Connect provider users can sign in with. From the environment,
+// providers are named in OIDC_PROVIDERS and configured with OIDC_<NAME>_* variables.
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name" toml:"name"`
+	Issuer       string   `yaml:"issuer" toml:"issuer"`
+	ClientID     string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" toml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url" toml:"redirect_url"`
+	Scopes       []string `yaml:"scopes" toml:"scopes"`
+}
+
+// Default returns the configuration of a profile before any file or environment is applied
+func Default(profile string) *Config {
+	cfg := &Config{
+		Profile: profile,
+		Server: ServerConfig{
+			Addr:            ":3000",
+			PublicURL:       "http://127.0.0.1:3000",
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:      "postgres",
+			Host:        "localhost",
+			Port:        5432,
+			User:        "postgres",
+			Name:        "backend",
+			SSLMode:     "disable",
+			AutoMigrate: true,
+			Pool: PoolConfig{
+				MaxOpenConns:    25,
+				MaxIdleConns:    10,
+				ConnMaxLifetime: 30 * time.Minute,
+				ConnMaxIdleTime: 5 * time.Minute,
+			},
+			Connect: ConnectConfig{
+				Attempts:   10,
+				Backoff:    time.Second,
+				MaxBackoff: 30 * time.Second,
+			},
+		},
+		Mail: MailConfig{
+			Port: 2525,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+			TokenStore:      "database",
+			TOTPIssuer:      "Backend",
+			LoginThrottle: LoginThrottleConfig{
+				MaxAccountFailures: 5,
+				MaxIPFailures:      20,
+				LockoutDuration:    15 * time.Minute,
+				BaseDelay:          time.Second,
+				MaxDelay:           30 * time.Second,
+			},
+			PasswordPolicy: PasswordPolicyConfig{
+				MinLength:   8,
+				MaxLength:   128,
+				HistorySize: 5,
+			},
+			Argon2: Argon2Config{
+				Memory:  64 * 1024,
+				Time:    3,
+				Threads: 2,
+			},
+			EmailVerification: EmailVerificationConfig{
+				Required:              true,
+				TokenTTL:              24 * time.Hour,
+				ResendInterval:        5 * time.Minute,
+				UnverifiedAccountDays: 7,
+				CleanupInterval:       time.Hour,
+			},
+			Session: SessionConfig{
+				TTL:            12 * time.Hour,
+				CookieSecure:   true,
+				CookieSameSite: "lax",
+			},
+		},
+	}
+
+	switch profile {
+	case ProfileProd:
+		// Migrations are applied explicitly with `backend migrate up`
+		cfg.Database.AutoMigrate = false
+	case ProfileDev:
+		// Served over plain HTTP on localhost
+		cfg.Auth.Session.CookieSecure = false
+	case ProfileTest:
+		// Fast, self-contained runs against a local SQLite file
+		cfg.Database.Driver = "sqlite"
+		cfg.Database.Name = "backend_test.db"
+		cfg.Database.Connect.Attempts = 1
+		cfg.Auth.TokenStore = "memory"
+		cfg.Auth.Argon2 = Argon2Config{Memory: 4 * 1024, Time: 1, Threads: 1}
+		cfg.Auth.Session.CookieSecure = false
+		cfg.Auth.EmailVerification.UnverifiedAccountDays = 0
+	}
+	return cfg
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration of the profile named by APP_ENV (dev by default): the profile
+// defaults, then .env, then the config file, then the environment, each overriding the previous.
+// The result is validated and every problem is reported in the returned error.
+func Load() (*Config, error) {
+	// .env is read without touching the environment so it can sit below the config file
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("could not read .env: %w", err)
+	}
+	fromDotenv := func(name string) string { return dotenv[name] }
+	lookup := func(name string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return dotenv[name]
+	}
+
+	profile := strings.TrimSpace(lookup("APP_ENV"))
+	if profile == "" {
+		profile = ProfileDev
+	}
+	if profile != ProfileDev && profile != ProfileTest && profile != ProfileProd {
+		return nil, fmt.Errorf("APP_ENV=%q is not a profile, use %s, %s or %s", profile, ProfileDev, ProfileTest, ProfileProd)
+	}
+	cfg := Default(profile)
+
+	if err := applyVariables(cfg, fromDotenv); err != nil {
+		return nil, err
+	}
+
+	path, err := configFile(profile, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := LoadFile(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyVariables(cfg, os.Getenv); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyVariables sets the settings and OIDC providers named by the variables getenv returns
+func applyVariables(cfg *Config, getenv func(string) string) error {
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), getenv); err != nil {
+		return err
+	}
+	if providers, ok := oidcFromEnv(getenv); ok {
+		cfg.Auth.OIDC = providers
+	}
+	return nil
+}
+
+// configFile returns the file named by CONFIG_FILE, or else the first of config.<profile>.yaml,
+// .yml, .toml and config.yaml, .yml, .toml that exists. No file is fine.
+func configFile(profile string, getenv func(string) string) (string, error) {
+	if path := getenv("CONFIG_FILE"); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("CONFIG_FILE: %w", err)
+		}
+		return path, nil
+	}
+
+	for _, base := range []string{"config." + profile, "config"} {
+		for _, ext := range []string{".yaml", ".yml", ".toml"} {
+			if _, err := os.Stat(base + ext); err == nil {
+				return base + ext, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// LoadFile applies a YAML or TOML file, chosen by extension, on top of cfg. Unknown keys are
+// rejected so typos do not go unnoticed.
+func LoadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	case ".toml":
+		metadata, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config file %s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// durationType is parsed with time.ParseDuration rather than as an integer
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv sets every field with an env tag whose variable is set and not empty
+func applyEnv(value reflect.Value, getenv func(string) string) error {
+	var errs []error
+	for i := 0; i < value.NumField(); i++ {
+		field, fieldValue := value.Type().Field(i), value.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(fieldValue, getenv); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw := strings.TrimSpace(getenv(name))
+		if raw == "" {
+			continue
+		}
+		if err := setField(fieldValue, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", name, raw, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setField parses raw into a string, bool, integer, duration or comma-separated list field
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("not a duration, use a value like 90s, 15m or 24h")
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("not a boolean, use true or false")
+		}
+		field.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Uint8, reflect.Uint32:
+		parsed, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("not an integer between 0 and %d", uint64(1)<<field.Type().Bits()-1)
+		}
+		field.SetUint(parsed)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// oidcFromEnv reads the providers named in OIDC_PROVIDERS (comma-separated), each configured with
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and optional _SCOPES
+func oidcFromEnv(getenv func(string) string) ([]OIDCProviderConfig, bool) {
+	names := strings.TrimSpace(getenv("OIDC_PROVIDERS"))
+	if names == "" {
+		return nil, false
+	}
+
+	providers := []OIDCProviderConfig{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       getenv(prefix + "ISSUER"),
+			ClientID:     getenv(prefix + "CLIENT_ID"),
+			ClientSecret: getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  getenv(prefix + "REDIRECT_URL"),
+		}
+		if scopes := getenv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		providers = append(providers, provider)
+	}
+	return providers, true
+}
@@ -0,0 +1,67 @@
+package config_test
+
+import (
+	"backend/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		dotenv string
+		file   string
+		env    string
+		want   string
+	}{
+		{name: "profile default", want: ":3000"},
+		{name: ".env over the default", dotenv: ":4000", want: ":4000"},
+		{name: "config file over .env", dotenv: ":4000", file: ":5000", want: ":5000"},
+		{name: "environment over the config file", dotenv: ":4000", file: ":5000", env: ":6000", want: ":6000"},
+		{name: "environment over .env", dotenv: ":4000", env: ":6000", want: ":6000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chdir(wd) })
+
+			t.Setenv("APP_ENV", config.ProfileTest)
+			t.Setenv("JWT_SECRET", "test-secret")
+			t.Setenv("SERVER_ADDR", tt.env)
+			t.Setenv("CONFIG_FILE", "")
+
+			dotenv := "JWT_SECRET=dotenv-secret\n"
+			if tt.dotenv != "" {
+				dotenv += "SERVER_ADDR=" + tt.dotenv + "\n"
+			}
+			if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(dotenv), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("server:\n  addr: \""+tt.file+"\"\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Addr != tt.want {
+				t.Fatalf("got address %q, want %q", cfg.Server.Addr, tt.want)
+			}
+			if cfg.Auth.JWT.Secret != "test-secret" {
+				t.Fatalf("got secret %q, want the environment's", cfg.Auth.JWT.Secret)
+			}
+		})
+	}
+}
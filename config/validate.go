@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// placeholderSecrets are the values .env.example ships for secrets, refused in prod
+var placeholderSecrets = []string{"change_me", "change_this_secret_in_production"}
+
+// Validate checks the configuration and reports every problem at once, naming the setting
+// and its environment variable. The prod profile adds checks for unsafe development values.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	prod := c.Profile == ProfileProd
+
+	check(c.Profile == ProfileDev || c.Profile == ProfileTest || c.Profile == ProfileProd,
+		"profile (APP_ENV) must be %s, %s or %s, got %q", ProfileDev, ProfileTest, ProfileProd, c.Profile)
+
+	// Server
+	check(c.Server.Addr != "", "server.addr (SERVER_ADDR) is required")
+	publicURL, err := url.Parse(c.Server.PublicURL)
+	check(err == nil && (publicURL.Scheme == "http" || publicURL.Scheme == "https") && publicURL.Host != "",
+		"server.public_url (PUBLIC_URL) must be an absolute http or https URL, got %q", c.Server.PublicURL)
+	if prod && err == nil {
+		check(publicURL.Scheme == "https", "server.public_url (PUBLIC_URL) must use https in prod")
+	}
+
+	if c.Server.ResetURL != "" {
+		resetURL, err := url.Parse(c.Server.ResetURL)
+		check(err == nil && (resetURL.Scheme == "http" || resetURL.Scheme == "https") && resetURL.Host != "",
+			"server.reset_url (PASSWORD_RESET_URL) must be an absolute http or https URL, got %q", c.Server.ResetURL)
+	}
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive")
+
+	// Database
+	database := c.Database
+	check(slices.Contains([]string{"postgres", "mysql", "sqlite"}, database.Driver),
+		"database.driver (DB_DRIVER) must be postgres, mysql or sqlite, got %q", database.Driver)
+	check(database.Name != "", "database.name (DB_NAME) is required")
+	if database.Driver != "sqlite" {
+		check(database.Host != "", "database.host (DB_HOST) is required")
+		check(validPort(database.Port), "database.port (DB_PORT) must be between 1 and 65535, got %d", database.Port)
+		check(database.User != "", "database.user (DB_USER) is required")
+		if prod {
+			check(database.Password != "", "database.password (DB_PASSWORD) is required in prod")
+			check(!slices.Contains(placeholderSecrets, database.Password),
+				"database.password (DB_PASSWORD) is still the .env.example placeholder")
+		}
+	}
+	if database.Driver == "postgres" {
+		check(slices.Contains([]string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}, database.SSLMode),
+			"database.sslmode (DB_SSLMODE) must be a Postgres sslmode, got %q", database.SSLMode)
+	}
+
+	pool := database.Pool
+	check(pool.MaxOpenConns >= 0, "database.pool.max_open_conns (DB_MAX_OPEN_CONNS) cannot be negative")
+	check(pool.MaxIdleConns >= 0, "database.pool.max_idle_conns (DB_MAX_IDLE_CONNS) cannot be negative")
+	check(pool.MaxOpenConns == 0 || pool.MaxIdleConns <= pool.MaxOpenConns,
+		"database.pool.max_idle_conns (DB_MAX_IDLE_CONNS) %d is above max_open_conns (DB_MAX_OPEN_CONNS) %d", pool.MaxIdleConns, pool.MaxOpenConns)
+	check(pool.ConnMaxLifetime >= 0, "database.pool.conn_max_lifetime (DB_CONN_MAX_LIFETIME) cannot be negative")
+	check(pool.ConnMaxIdleTime >= 0, "database.pool.conn_max_idle_time (DB_CONN_MAX_IDLE_TIME) cannot be negative")
+	connect := database.Connect
+	check(connect.Attempts > 0, "database.connect.attempts (DB_CONNECT_ATTEMPTS) must be positive")
+	check(connect.Backoff > 0 && connect.MaxBackoff >= connect.Backoff,
+		"database.connect.backoff (DB_CONNECT_BACKOFF) must be positive and at most max_backoff (DB_CONNECT_MAX_BACKOFF)")
+
+	// Mail
+	check(validPort(c.Mail.Port), "mail.port (MAIL_PORT) must be between 1 and 65535, got %d", c.Mail.Port)
+	if prod {
+		check(c.Mail.Host != "", "mail.host (MAIL_HOST) is required in prod")
+		check(c.Mail.From != "", "mail.from (MAIL_FROM_ADDRESS) is required in prod")
+	}
+
+	// Tokens
+	jwt := c.Auth.JWT
+	check(jwt.Secret != "" || len(jwt.Keys) > 0, "auth.jwt.secret (JWT_SECRET) or auth.jwt.keys (JWT_KEYS) is required")
+	for _, entry := range jwt.Keys {
+		check(len(strings.SplitN(entry, ":", 3)) == 3, "auth.jwt.keys (JWT_KEYS) entry %q must be kid:alg:path", entry)
+	}
+	if prod && len(jwt.Keys) == 0 {
+		check(len(jwt.Secret) >= 32, "auth.jwt.secret (JWT_SECRET) must be at least 32 characters in prod")
+		check(!slices.Contains(placeholderSecrets, jwt.Secret), "auth.jwt.secret (JWT_SECRET) is still the .env.example placeholder")
+	}
+	check(c.Auth.AccessTokenTTL > 0, "auth.access_token_ttl (ACCESS_TOKEN_TTL) must be positive")
+	check(c.Auth.RefreshTokenTTL > c.Auth.AccessTokenTTL,
+		"auth.refresh_token_ttl (REFRESH_TOKEN_TTL) must be longer than auth.access_token_ttl (ACCESS_TOKEN_TTL)")
+	check(slices.Contains([]string{"database", "postgres", "memory"}, c.Auth.TokenStore),
+		"auth.token_store (TOKEN_STORE) must be database or memory, got %q", c.Auth.TokenStore)
+	if prod {
+		check(c.Auth.TokenStore != "memory", "auth.token_store (TOKEN_STORE) must be database in prod")
+	}
+	check(c.Auth.AdminEmail == "" || strings.Contains(c.Auth.AdminEmail, "@"),
+		"auth.admin_email (ADMIN_EMAIL) must be an email address, got %q", c.Auth.AdminEmail)
+	check(c.Auth.TOTPIssuer != "", "auth.totp_issuer (TOTP_ISSUER) is required")
+
+	// Login throttling
+	throttle := c.Auth.LoginThrottle
+	check(throttle.MaxAccountFailures > 0, "auth.login_throttle.max_attempts (LOGIN_MAX_ATTEMPTS) must be positive")
+	check(throttle.MaxIPFailures > 0, "auth.login_throttle.ip_max_attempts (LOGIN_IP_MAX_ATTEMPTS) must be positive")
+	check(throttle.LockoutDuration > 0, "auth.login_throttle.lockout_duration (LOGIN_LOCKOUT_DURATION) must be positive")
+	check(throttle.BaseDelay > 0 && throttle.MaxDelay >= throttle.BaseDelay,
+		"auth.login_throttle.base_delay (LOGIN_BASE_DELAY) must be positive and at most max_delay (LOGIN_MAX_DELAY)")
+
+	// Passwords
+	policy := c.Auth.PasswordPolicy
+	check(policy.MinLength > 0, "auth.password_policy.min_length (PASSWORD_MIN_LENGTH) must be positive")
+	check(policy.MaxLength >= policy.MinLength,
+		"auth.password_policy.max_length (PASSWORD_MAX_LENGTH) %d is below min_length (PASSWORD_MIN_LENGTH) %d", policy.MaxLength, policy.MinLength)
+	check(policy.HistorySize >= 0, "auth.password_policy.history (PASSWORD_HISTORY) cannot be negative")
+	argon2 := c.Auth.Argon2
+	check(argon2.Time > 0, "auth.argon2.time (ARGON2_TIME) must be positive")
+	check(argon2.Threads > 0, "auth.argon2.threads (ARGON2_THREADS) must be positive")
+	check(argon2.Memory >= 8*uint32(argon2.Threads), "auth.argon2.memory (ARGON2_MEMORY) must be at least 8 KiB per thread")
+
+	// Email verification
+	verification := c.Auth.EmailVerification
+	check(verification.TokenTTL > 0, "auth.email_verification.ttl (EMAIL_VERIFICATION_TTL) must be positive")
+	check(verification.ResendInterval >= 0, "auth.email_verification.resend_interval (EMAIL_VERIFICATION_RESEND_INTERVAL) cannot be negative")
+	check(verification.UnverifiedAccountDays >= 0, "auth.email_verification.unverified_account_days (UNVERIFIED_ACCOUNT_DAYS) cannot be negative")
+	check(verification.CleanupInterval > 0, "auth.email_verification.cleanup_interval (UNVERIFIED_CLEANUP_INTERVAL) must be positive")
+
+	// Cookie sessions
+	session := c.Auth.Session
+	check(session.TTL > 0, "auth.session.ttl (SESSION_TTL) must be positive")
+	check(slices.Contains([]string{"lax", "strict"}, strings.ToLower(session.CookieSameSite)),
+		"auth.session.cookie_samesite (SESSION_COOKIE_SAMESITE) must be lax or strict, got %q", session.CookieSameSite)
+	if prod {
+		check(session.CookieSecure, "auth.session.cookie_secure (SESSION_COOKIE_SECURE) must be true in prod")
+	}
+
+	// OpenID Connect
+	seen := map[string]bool{}
+	for _, provider := range c.Auth.OIDC {
+		prefix := "OIDC_" + strings.ToUpper(provider.Name) + "_"
+		check(provider.Name != "" && !seen[provider.Name], "auth.oidc provider names must be set and unique, got %q", provider.Name)
+		seen[provider.Name] = true
+		check(provider.Issuer != "" && provider.ClientID != "" && provider.RedirectURL != "",
+			"auth.oidc provider %s needs issuer, client_id and redirect_url (%sISSUER, %sCLIENT_ID, %sREDIRECT_URL)",
+			provider.Name, prefix, prefix, prefix)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validPort reports whether port is a usable TCP port
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
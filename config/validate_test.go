@@ -0,0 +1,44 @@
+package config_test
+
+import (
+	"backend/config"
+	"strings"
+	"testing"
+)
+
+func TestValidateSecrets(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    string
+		dbPassword string
+		jwtSecret  string
+		wantErr    string // Empty when the configuration is valid
+	}{
+		{name: "prod with real secrets", profile: config.ProfileProd, dbPassword: "db-password", jwtSecret: strings.Repeat("s", 40)},
+		{name: "prod without database password", profile: config.ProfileProd, jwtSecret: strings.Repeat("s", 40), wantErr: "DB_PASSWORD"},
+		{name: "prod with placeholder database password", profile: config.ProfileProd, dbPassword: "change_me", jwtSecret: strings.Repeat("s", 40), wantErr: "DB_PASSWORD"},
+		{name: "prod without JWT secret", profile: config.ProfileProd, dbPassword: "db-password", wantErr: "JWT_SECRET"},
+		{name: "prod with placeholder JWT secret", profile: config.ProfileProd, dbPassword: "db-password", jwtSecret: "change_this_secret_in_production", wantErr: "JWT_SECRET"},
+		{name: "dev with placeholders", profile: config.ProfileDev, dbPassword: "change_me", jwtSecret: "change_this_secret_in_production"},
+		{name: "test with placeholders", profile: config.ProfileTest, dbPassword: "change_me", jwtSecret: "change_this_secret_in_production"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default(tt.profile)
+			cfg.Server.PublicURL = "https://app.example.com"
+			cfg.Mail.Host = "smtp.example.com"
+			cfg.Mail.From = "noreply@example.com"
+			cfg.Database.Password = tt.dbPassword
+			cfg.Auth.JWT.Secret = tt.jwtSecret
+
+			err := cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("got error %v, want none", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("got error %v, want one about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"backend/custom"
+	"backend/model"
+	"backend/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// GetAPIKeys lists the current user's API keys
+func GetAPIKeys(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		var keys []model.APIKey
+		if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not retrieve API keys", fiber.StatusInternalServerError))
+		}
+
+		return c.JSON(keys)
+	}
+}
+
+// CreateAPIKey issues an API key for the current user, limited to permissions the user holds
+func CreateAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		principal, err := custom.CurrentPrincipal(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+		if principal.Type != utils.PrincipalUser {
+			return custom.SendErrorResponse(c, custom.NewHttpError("API keys cannot create API keys", fiber.StatusForbidden))
+		}
+
+		var request model.APIKeyRequest
+		if httpErr := bindAPIKeyRequest(c, &request, principal.Permissions); httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		userID := principal.UserID
+		return createAPIKey(c, db, model.APIKey{UserID: &userID}, request)
+	}
+}
+
+// UpdateAPIKey changes the name, scopes or expiry of one of the current user's API keys
+func UpdateAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		principal, err := custom.CurrentPrincipal(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+		if principal.Type != utils.PrincipalUser {
+			return custom.SendErrorResponse(c, custom.NewHttpError("API keys cannot manage API keys", fiber.StatusForbidden))
+		}
+
+		apiKey, httpErr := findUserAPIKey(db, principal.UserID, c.Params("id"))
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		var request model.APIKeyRequest
+		if httpErr := bindAPIKeyRequest(c, &request, principal.Permissions); httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		apiKey.Name = request.Name
+		apiKey.Scopes = request.Scopes
+		apiKey.ExpiresAt = request.ExpiresAt
+		if err := db.Select("name", "scopes", "expires_at").Save(apiKey).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not update API key", fiber.StatusInternalServerError))
+		}
+
+		return c.JSON(apiKey)
+	}
+}
+
+// DeleteAPIKey revokes one of the current user's API keys
+func DeleteAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		apiKey, httpErr := findUserAPIKey(db, userID, c.Params("id"))
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		if err := db.Delete(apiKey).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not revoke API key", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "API key revoked successfully",
+		})
+	}
+}
+
+// GetServiceAccounts lists the service accounts with their roles
+func GetServiceAccounts(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var accounts []model.ServiceAccount
+		if err := db.Preload("Roles").Find(&accounts).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not retrieve service accounts", fiber.StatusInternalServerError))
+		}
+
+		return c.JSON(accounts)
+	}
+}
+
+// CreateServiceAccount creates a non-human account that owns API keys through its roles
+func CreateServiceAccount(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request model.ServiceAccountRequest
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		var roles []model.Role
+		if len(request.Roles) > 0 {
+			if err := db.Where("name IN ?", request.Roles).Find(&roles).Error; err != nil {
+				return custom.SendErrorResponse(c, custom.NewHttpError("Could not load roles", fiber.StatusInternalServerError))
+			}
+			if len(roles) != len(request.Roles) {
+				return custom.SendErrorResponse(c, custom.NewHttpError("Role not found", fiber.StatusNotFound))
+			}
+		}
+
+		var existing model.ServiceAccount
+		if err := db.Where("name = ?", request.Name).First(&existing).Error; err == nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Service account already exists", fiber.StatusConflict))
+		}
+
+		account := model.ServiceAccount{Name: request.Name, Roles: roles}
+		if err := db.Create(&account).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not create service account", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(account)
+	}
+}
+
+// GetServiceAccountAPIKeys lists the API keys of a service account
+func GetServiceAccountAPIKeys(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		account, httpErr := findServiceAccount(db, c.Params("id"))
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		var keys []model.APIKey
+		if err := db.Where("service_account_id = ?", account.ID).Order("created_at DESC").Find(&keys).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not retrieve API keys", fiber.StatusInternalServerError))
+		}
+
+		return c.JSON(keys)
+	}
+}
+
+// CreateServiceAccountAPIKey issues an API key for a service account, limited to its permissions
+func CreateServiceAccountAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		account, httpErr := findServiceAccount(db, c.Params("id"))
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		permissions, err := utils.ServiceAccountPermissions(db, account.ID)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not load service account permissions", fiber.StatusInternalServerError))
+		}
+
+		var request model.APIKeyRequest
+		if httpErr := bindAPIKeyRequest(c, &request, permissions); httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		return createAPIKey(c, db, model.APIKey{ServiceAccountID: &account.ID}, request)
+	}
+}
+
+// RevokeAPIKey deletes any API key, whoever owns it
+func RevokeAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		keyID, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest))
+		}
+
+		result := db.Delete(&model.APIKey{}, keyID)
+		if result.Error != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not revoke API key", fiber.StatusInternalServerError))
+		}
+		if result.RowsAffected == 0 {
+			return custom.SendErrorResponse(c, custom.NewHttpError("API key not found", fiber.StatusNotFound))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "API key revoked successfully",
+		})
+	}
+}
+
+// bindAPIKeyRequest parses an API key request and checks its scopes and expiry
+func bindAPIKeyRequest(c fiber.Ctx, request *model.APIKeyRequest, allowed []string) *custom.HttpError {
+	if err := c.Bind().Body(request); err != nil {
+		log.Printf("Validation errors: %+v", err)
+		return custom.NewHttpError(err.Error(), fiber.StatusBadRequest)
+	}
+
+	if err := utils.ValidateScopes(request.Scopes, allowed); err != nil {
+		return custom.NewHttpError(err.Error(), fiber.StatusForbidden)
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return custom.NewHttpError("Expiry must be in the future", fiber.StatusBadRequest)
+	}
+	return nil
+}
+
+// createAPIKey stores a new key for the owner set on apiKey and returns the plain key, which is shown only once
+func createAPIKey(c fiber.Ctx, db *gorm.DB, apiKey model.APIKey, request model.APIKeyRequest) error {
+	key, prefix, hash := utils.GenerateAPIKey()
+
+	apiKey.Name = request.Name
+	apiKey.Prefix = prefix
+	apiKey.KeyHash = hash
+	apiKey.Scopes = request.Scopes
+	apiKey.ExpiresAt = request.ExpiresAt
+	if err := db.Create(&apiKey).Error; err != nil {
+		return custom.SendErrorResponse(c, custom.NewHttpError("Could not create API key", fiber.StatusInternalServerError))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "API key created, store it now as it will not be shown again",
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+// findUserAPIKey loads an API key by ID if it belongs to the user
+func findUserAPIKey(db *gorm.DB, userID uint, id string) (*model.APIKey, *custom.HttpError) {
+	keyID, err := custom.ParseID(id)
+	if err != nil {
+		return nil, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest)
+	}
+
+	var apiKey model.APIKey
+	if err := db.Where("id = ? AND user_id = ?", keyID, userID).First(&apiKey).Error; err != nil {
+		return nil, custom.NewHttpError("API key not found", fiber.StatusNotFound)
+	}
+	return &apiKey, nil
+}
+
+// findServiceAccount loads a service account by ID
+func findServiceAccount(db *gorm.DB, id string) (*model.ServiceAccount, *custom.HttpError) {
+	accountID, err := custom.ParseID(id)
+	if err != nil {
+		return nil, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest)
+	}
+
+	var account model.ServiceAccount
+	if err := db.First(&account, accountID).Error; err != nil {
+		return nil, custom.NewHttpError("Service account not found", fiber.StatusNotFound)
+	}
+	return &account, nil
+}
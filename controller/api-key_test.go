@@ -0,0 +1,131 @@
+package controller_test
+
+import (
+	"backend/model"
+	"backend/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "scope the user holds", body: `{"name":"reader","scopes":["branch:read"]}`, wantStatus: fiber.StatusCreated},
+		{name: "scope the user lacks", body: `{"name":"deleter","scopes":["person:delete"]}`, wantStatus: fiber.StatusForbidden},
+		{name: "past expiry", body: `{"name":"old","scopes":["branch:read"],"expires_at":"2000-01-01T00:00:00Z"}`, wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			newTestUser(t, db, "user@example.com", "password1")
+			token, _ := loginTokens(t, app, "user@example.com", "password1")
+
+			resp, body := send(t, app, fiber.MethodPost, "/api/person/api-keys", tt.body, bearer(token))
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %v", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantStatus != fiber.StatusCreated {
+				return
+			}
+
+			// Only the hash of the key is stored
+			key, _ := body["key"].(string)
+			var apiKey model.APIKey
+			if err := db.First(&apiKey).Error; err != nil {
+				t.Fatal(err)
+			}
+			if key == "" || apiKey.KeyHash != utils.TokenKey(key) || strings.Contains(apiKey.KeyHash, key) {
+				t.Fatalf("stored hash %q does not match key %q", apiKey.KeyHash, key)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	app, db := newTestApp(t)
+	if err := db.Create(&model.Branch{BranchData: []byte(`{"name":"main"}`)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	newTestUser(t, db, "user@example.com", "password1")
+	token, _ := loginTokens(t, app, "user@example.com", "password1")
+	reader := createTestAPIKey(t, app, token, `{"name":"reader","scopes":["branch:read"]}`)
+	expired := createTestAPIKey(t, app, token, `{"name":"expired","scopes":["branch:read"]}`)
+	setAPIKeyExpiry(t, db, expired, time.Now().Add(-time.Minute))
+
+	// A key keeps only the scopes its owner still holds
+	demotedUser := newTestUser(t, db, "demoted@example.com", "password1")
+	demotedToken, _ := loginTokens(t, app, "demoted@example.com", "password1")
+	demoted := createTestAPIKey(t, app, demotedToken, `{"name":"reader","scopes":["branch:read"]}`)
+	if err := db.Model(demotedUser).Association("Roles").Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "valid key with the scope", key: reader, wantStatus: fiber.StatusOK},
+		{name: "key whose owner lost the permission", key: demoted, wantStatus: fiber.StatusForbidden},
+		{name: "tampered key", key: reader[:len(reader)-1] + flip(reader[len(reader)-1]), wantStatus: fiber.StatusUnauthorized, wantError: "Invalid or expired API key"},
+		{name: "malformed key", key: "not-a-key", wantStatus: fiber.StatusUnauthorized, wantError: "Invalid or expired API key"},
+		{name: "expired key", key: expired, wantStatus: fiber.StatusUnauthorized, wantError: "Invalid or expired API key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := send(t, app, fiber.MethodGet, "/api/protected/all-data", "", map[string]string{"X-API-Key": tt.key})
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %v", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantError != "" && body["error"] != tt.wantError {
+				t.Fatalf("got error %v, want %q", body["error"], tt.wantError)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareHidesTokenErrors(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	resp, body := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer("not.a.token"))
+	if resp.StatusCode != fiber.StatusUnauthorized || body["error"] != "Invalid or expired token" {
+		t.Fatalf("got %d: %v", resp.StatusCode, body)
+	}
+}
+
+// createTestAPIKey creates an API key for the logged in user and returns the plain key
+func createTestAPIKey(t *testing.T, app *fiber.App, token string, request string) string {
+	t.Helper()
+	resp, body := send(t, app, fiber.MethodPost, "/api/person/api-keys", request, bearer(token))
+	key, _ := body["key"].(string)
+	if resp.StatusCode != fiber.StatusCreated || key == "" {
+		t.Fatalf("API key creation answered with %d: %v", resp.StatusCode, body)
+	}
+	return key
+}
+
+// setAPIKeyExpiry moves the expiry of the stored key
+func setAPIKeyExpiry(t *testing.T, db *gorm.DB, key string, expiresAt time.Time) {
+	t.Helper()
+	if err := db.Model(&model.APIKey{}).Where("key_hash = ?", utils.TokenKey(key)).Update("expires_at", expiresAt).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// flip returns a different character than c, keeping the key well formed
+func flip(c byte) string {
+	if c == 'a' {
+		return "b"
+	}
+	return "a"
+}
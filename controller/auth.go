@@ -5,9 +5,12 @@ import (
 	"backend/model"
 	"backend/utils" // Import your JWT utility
 	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -36,52 +39,210 @@ func Login(db *gorm.DB) fiber.Handler {
 			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
 		}
 
-		// Log the received request body
-		log.Printf("Received request body: %s", body)
-
-		log.Printf("Bound userAuth: %+v", userAuth)
+		// Refuse attempts while the account or IP is locked or still backing off
+		wait, err := utils.CheckLoginThrottle(db, userAuth.Email, c.IP())
+		if err != nil {
+			err := custom.NewHttpError("Could not check login attempts", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+		if wait > 0 {
+			return tooManyLoginAttempts(c, wait)
+		}
 
 		var user model.User
-		if err := db.Where("email = ?", userAuth.Email).First(&user).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				err := custom.NewHttpError("Invalid invalid email", fiber.StatusBadRequest)
-				return custom.SendErrorResponse(c, err)
-			}
+		if err := db.Where("email = ?", userAuth.Email).First(&user).Error; err != nil && err != gorm.ErrRecordNotFound {
 			err := custom.NewHttpError("Could not find user", fiber.StatusInternalServerError)
 			return custom.SendErrorResponse(c, err)
 		}
 
-		// Compare the provided password with the hashed password
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(userAuth.Password)); err != nil {
-			err := custom.NewHttpError("Invalid password", fiber.StatusBadRequest)
-			return custom.SendErrorResponse(c, err)
+		// Compare the provided password with the hashed password, unknown emails are compared
+		// against a dummy hash so both cases take the same time and return the same error
+		hashedPassword := user.Password
+		if user.ID == 0 {
+			hashedPassword = dummyPasswordHash()
+		}
+		match, needsRehash, err := utils.VerifyPassword(hashedPassword, userAuth.Password)
+		if err != nil {
+			log.Printf("Could not verify password of user %d: %v", user.ID, err)
+		}
+		if !match || user.ID == 0 {
+			locked, err := utils.RecordLoginFailure(db, userAuth.Email, c.IP())
+			if err != nil {
+				log.Printf("Could not record failed login: %v", err)
+			}
+			recordSecurityEvent(c, db, user.ID, userAuth.Email, model.SecurityLoginFailure, "Invalid email or password")
+			if locked {
+				recordSecurityEvent(c, db, user.ID, userAuth.Email, model.SecurityLoginLockout, "")
+				return tooManyLoginAttempts(c, 0)
+			}
+			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid email or password", fiber.StatusBadRequest))
 		}
 
-		// Check if the current token is still active
-		if existingToken, err := custom.ExtractToken(c); err == nil {
-			if _, err := utils.ValidateToken(existingToken); err == nil {
-				err := custom.NewHttpError("A valid token is already active", fiber.StatusForbidden)
-				return custom.SendErrorResponse(c, err)
+		// Clear the failed attempts of the account
+		if err := utils.ResetLoginFailures(db, userAuth.Email); err != nil {
+			log.Printf("Could not reset failed logins: %v", err)
+		}
+
+		// Upgrade bcrypt and outdated argon2id hashes now that the plain password is known
+		if needsRehash {
+			if hash, err := utils.HashPassword(userAuth.Password); err != nil {
+				log.Printf("Could not rehash password of user %d: %v", user.ID, err)
+			} else if err := db.Model(&user).Update("password", hash).Error; err != nil {
+				log.Printf("Could not store rehashed password of user %d: %v", user.ID, err)
 			}
 		}
 
-		// Generate a new JWT token
-		token, err := utils.GenerateJWT(user.ID, "")
+		return completeLogin(c, db, &user)
+	}
+}
+
+// completeLogin issues the tokens of an authenticated user, or an interim token when the
+// account still has to pass two-factor authentication
+func completeLogin(c fiber.Ctx, db *gorm.DB, user *model.User) error {
+	// Unverified accounts cannot log in until they follow the emailed link
+	if utils.EmailVerificationRequired() && !user.IsVerified {
+		err := custom.NewHttpError("Please verify your email before logging in", fiber.StatusForbidden)
+		return custom.SendErrorResponse(c, err)
+	}
+
+	// Accounts with two-factor authentication finish the login with a code
+	twoFactorEnabled, err := utils.TwoFactorEnabled(db, user.ID)
+	if err != nil {
+		err := custom.NewHttpError("Could not load two-factor settings", fiber.StatusInternalServerError)
+		return custom.SendErrorResponse(c, err)
+	}
+	if twoFactorEnabled {
+		interimToken, err := utils.IssueInterimToken(user.ID)
 		if err != nil {
 			err := custom.NewHttpError("Could not generate token", fiber.StatusInternalServerError)
 			return custom.SendErrorResponse(c, err)
 		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":             "Two-factor authentication required",
+			"two_factor_required": true,
+			"interim_token":       interimToken,
+		})
+	}
+
+	return issueLogin(c, db, user.ID, false)
+}
+
+// issueLogin returns an access and refresh token pair, or starts a cookie session when the
+// client logs in with ?mode=session. mfa is set when the login passed the second factor.
+// The login is added to the user's security timeline.
+func issueLogin(c fiber.Ctx, db *gorm.DB, userID uint, mfa bool) error {
+	if c.Query("mode") == "session" {
+		csrfToken, err := utils.StartWebSession(c, db, userID, mfa, requestDevice(c))
+		if err != nil {
+			log.Printf("Could not start session of user %d: %v", userID, err)
+			err := custom.NewHttpError("Could not start session", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+		utils.RecordLoginSuccess(db, userID, requestDevice(c))
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":    "Login successful",
+			"csrf_token": csrfToken,
+		})
+	}
+
+	// Generate a new access and refresh token pair for this device
+	pair, err := utils.IssueTokenPair(db, userID, mfa, requestDevice(c))
+	if err != nil {
+		err := custom.NewHttpError("Could not generate token", fiber.StatusInternalServerError)
+		return custom.SendErrorResponse(c, err)
+	}
+	utils.RecordLoginSuccess(db, userID, requestDevice(c))
+
+	// Return the generated tokens to the user
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":       "Login successful",
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+	})
+}
+
+// dummyPasswordHash is compared against when the email is unknown. It is hashed on first use,
+// after the configured argon2 cost has been applied, so it takes as long as a real hash.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := utils.HashPassword(utils.GenerateVerificationToken())
+	if err != nil {
+		log.Printf("Could not create dummy password hash: %v", err)
+	}
+	return hash
+})
+
+// tooManyLoginAttempts rejects a throttled login, telling the client when to retry
+func tooManyLoginAttempts(c fiber.Ctx, wait time.Duration) error {
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
+	err := custom.NewHttpError("Too many login attempts, please try again later", fiber.StatusTooManyRequests)
+	return custom.SendErrorResponse(c, err)
+}
+
+// verifyCurrentPassword confirms a change to the account with the user's password. Wrong
+// passwords are throttled per user like logins and added to the security timeline.
+func verifyCurrentPassword(c fiber.Ctx, db *gorm.DB, user *model.User, password string) *custom.HttpError {
+	wait, err := utils.CheckPasswordConfirmThrottle(db, user.ID)
+	if err != nil {
+		return custom.NewHttpError("Could not check password attempts", fiber.StatusInternalServerError)
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return custom.NewHttpError("Too many password attempts, please try again later", fiber.StatusTooManyRequests)
+	}
+
+	if ok, _, _ := utils.VerifyPassword(user.Password, password); !ok {
+		locked, err := utils.RecordPasswordConfirmFailure(db, user.ID)
+		if err != nil {
+			log.Printf("Could not record failed password check: %v", err)
+		}
+		recordSecurityEvent(c, db, user.ID, user.Email, model.SecurityPasswordConfirm, "")
+		if locked {
+			return custom.NewHttpError("Too many password attempts, please try again later", fiber.StatusTooManyRequests)
+		}
+		return custom.NewHttpError("Invalid password", fiber.StatusBadRequest)
+	}
+
+	if err := utils.ResetPasswordConfirmFailures(db, user.ID); err != nil {
+		log.Printf("Could not reset failed password checks: %v", err)
+	}
+	return nil
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair
+func Refresh(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request model.RefreshTokenRequest
+
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		// Rotate the refresh token, a replayed token revokes its whole family
+		pair, err := utils.RotateRefreshToken(db, request.RefreshToken, requestDevice(c))
+		if err != nil {
+			switch err {
+			case utils.ErrTokenNotFound, utils.ErrTokenExpired, utils.ErrTokenReused:
+				return custom.SendErrorResponse(c, custom.NewHttpError("Invalid refresh token", fiber.StatusUnauthorized))
+			}
+			log.Printf("Could not rotate refresh token: %v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not refresh token", fiber.StatusInternalServerError))
+		}
 
-		// Return the generated token to the user
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"message": "Login successful",
-			"token":   token,
+			"message":       "Token refreshed",
+			"token":         pair.AccessToken,
+			"refresh_token": pair.RefreshToken,
+			"expires_in":    pair.ExpiresIn,
 		})
 	}
 }
 
 // Logout handles user logout
-func Logout() fiber.Handler {
+func Logout(db *gorm.DB) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		// Get the token from the Authorization header
 		jwtToken, err := custom.ExtractToken(c)
@@ -90,14 +251,22 @@ func Logout() fiber.Handler {
 			return custom.SendErrorResponse(c, err)
 		}
 
+		// Remember which refresh token family the token belongs to
+		info, _ := utils.LookupToken(jwtToken)
+
 		// Delete the token from active tokens
 		if err := utils.DeleteToken(jwtToken); err != nil {
 			err := custom.NewHttpError("no token found", fiber.StatusInternalServerError)
 			return custom.SendErrorResponse(c, err)
 		}
 
-		// Log the action for debugging
-		log.Printf("Token %s has been marked as inactive.", jwtToken)
+		// Revoke the refresh tokens issued with this login
+		if info.Family != "" {
+			if err := utils.RevokeTokenFamily(db, info.Family); err != nil {
+				err := custom.NewHttpError("Could not revoke refresh token", fiber.StatusInternalServerError)
+				return custom.SendErrorResponse(c, err)
+			}
+		}
 
 		// Invalidate token by instructing the client to remove it
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logout successful. Please remove the token from storage."})
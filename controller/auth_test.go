@@ -0,0 +1,230 @@
+package controller_test
+
+import (
+	"backend/config"
+	"backend/model"
+	"backend/utils"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// setNoDelayThrottle locks accounts after maxFailures without delaying the attempts before
+func setNoDelayThrottle(t *testing.T, maxFailures int) {
+	utils.SetLoginThrottleConfig(config.LoginThrottleConfig{
+		MaxAccountFailures: maxFailures,
+		MaxIPFailures:      100,
+		LockoutDuration:    time.Minute,
+	})
+	t.Cleanup(func() { utils.SetLoginThrottleConfig(config.Default(config.ProfileTest).Auth.LoginThrottle) })
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		password   string
+		unverified bool
+		wantStatus int
+	}{
+		{name: "valid credentials", email: "user@example.com", password: "password1", wantStatus: fiber.StatusOK},
+		{name: "wrong password", email: "user@example.com", password: "password2", wantStatus: fiber.StatusBadRequest},
+		{name: "unknown email", email: "nobody@example.com", password: "password1", wantStatus: fiber.StatusBadRequest},
+		{name: "unverified account", email: "user@example.com", password: "password1", unverified: true, wantStatus: fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			user := newTestUser(t, db, "user@example.com", "password1")
+			if tt.unverified {
+				if err := db.Model(user).Update("is_verified", false).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resp, body := login(t, app, tt.email, tt.password)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %v", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			token, _ := body["token"].(string)
+			if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(token)); resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("access token rejected with %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestLoginSameErrorForUnknownEmail(t *testing.T) {
+	app, db := newTestApp(t)
+	setNoDelayThrottle(t, 10)
+	newTestUser(t, db, "user@example.com", "password1")
+
+	_, wrongPassword := login(t, app, "user@example.com", "password2")
+	_, unknownEmail := login(t, app, "nobody@example.com", "password1")
+	if fmt.Sprint(wrongPassword) != fmt.Sprint(unknownEmail) {
+		t.Fatalf("responses differ: %v and %v", wrongPassword, unknownEmail)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	app, db := newTestApp(t)
+	setNoDelayThrottle(t, 3)
+	newTestUser(t, db, "user@example.com", "password1")
+
+	for i, want := range []int{fiber.StatusBadRequest, fiber.StatusBadRequest, fiber.StatusTooManyRequests} {
+		if resp, _ := login(t, app, "user@example.com", "password2"); resp.StatusCode != want {
+			t.Fatalf("failure %d answered with %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+
+	// The right password is refused while the account is locked
+	resp, _ := login(t, app, "user@example.com", "password1")
+	if resp.StatusCode != fiber.StatusTooManyRequests || resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+		t.Fatalf("locked login answered with %d and Retry-After %q", resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+
+	var events int64
+	if err := db.Model(&model.SecurityEvent{}).Where("type = ?", model.SecurityLoginLockout).Count(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if events != 1 {
+		t.Fatalf("got %d lockout events, want 1", events)
+	}
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	app, db := newTestApp(t)
+	newTestUser(t, db, "user@example.com", "password1")
+
+	_, body := login(t, app, "user@example.com", "password1")
+	refreshToken, _ := body["refresh_token"].(string)
+
+	refresh := func(token string) (*http.Response, map[string]any) {
+		return send(t, app, fiber.MethodPost, "/api/person/refresh", fmt.Sprintf(`{"refresh_token":%q}`, token), nil)
+	}
+	resp, rotated := refresh(refreshToken)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("refresh answered with %d: %v", resp.StatusCode, rotated)
+	}
+	accessToken, _ := rotated["token"].(string)
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(accessToken)); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("rotated access token rejected with %d", resp.StatusCode)
+	}
+
+	// Replaying the rotated refresh token ends the whole login
+	if resp, _ := refresh(refreshToken); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("replayed refresh token answered with %d", resp.StatusCode)
+	}
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(accessToken)); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("access token of a replayed family answered with %d", resp.StatusCode)
+	}
+	if resp, _ := refresh(rotated["refresh_token"].(string)); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("refresh token of a replayed family answered with %d", resp.StatusCode)
+	}
+
+	// Logging out revokes the access and refresh tokens of the login
+	_, body = login(t, app, "user@example.com", "password1")
+	accessToken, _ = body["token"].(string)
+	if resp, _ := send(t, app, fiber.MethodPost, "/api/person/logout", "", bearer(accessToken)); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("logout answered with %d", resp.StatusCode)
+	}
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(accessToken)); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("logged out access token answered with %d", resp.StatusCode)
+	}
+	if resp, _ := refresh(body["refresh_token"].(string)); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("logged out refresh token answered with %d", resp.StatusCode)
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	app, db := newTestApp(t)
+	setNoDelayThrottle(t, 10)
+	user := newTestUser(t, db, "user@example.com", "password1")
+	if err := db.Create(&model.TwoFactor{UserID: user.ID, Secret: utils.NewTOTPSecret(), Enabled: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	codes, err := utils.GenerateRecoveryCodes(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := login(t, app, "user@example.com", "password1")
+	interimToken, _ := body["interim_token"].(string)
+	if resp.StatusCode != fiber.StatusOK || body["two_factor_required"] != true || body["token"] != nil {
+		t.Fatalf("login answered with %d: %v", resp.StatusCode, body)
+	}
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(interimToken)); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("interim token accepted as an access token with %d", resp.StatusCode)
+	}
+
+	secondFactor := func(code string) (*http.Response, map[string]any) {
+		return send(t, app, fiber.MethodPost, "/api/person/login/2fa", fmt.Sprintf(`{"interim_token":%q,"code":%q}`, interimToken, code), nil)
+	}
+	if resp, _ := secondFactor("000000"); resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("wrong code answered with %d", resp.StatusCode)
+	}
+	resp, body = secondFactor(codes[0])
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("recovery code answered with %d: %v", resp.StatusCode, body)
+	}
+	claims, err := utils.ValidateToken(body["token"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["mfa"] != true {
+		t.Fatalf("got mfa claim %v, want true", claims["mfa"])
+	}
+
+	// The interim token and the recovery code are single use
+	if resp, _ := secondFactor(codes[1]); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("reused interim token answered with %d", resp.StatusCode)
+	}
+}
+
+func TestWebSessionLogin(t *testing.T) {
+	app, db := newTestApp(t)
+	newTestUser(t, db, "user@example.com", "password1")
+
+	resp, body := send(t, app, fiber.MethodPost, "/api/person/login?mode=session", `{"email":"user@example.com","password":"password1"}`, nil)
+	if resp.StatusCode != fiber.StatusOK || body["token"] != nil {
+		t.Fatalf("session login answered with %d: %v", resp.StatusCode, body)
+	}
+	csrfToken, _ := body["csrf_token"].(string)
+	var sessionCookie string
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == utils.WebSessionCookie {
+			if !cookie.HttpOnly {
+				t.Fatal("session cookie is readable by scripts")
+			}
+			sessionCookie = cookie.Name + "=" + cookie.Value
+		}
+	}
+	cookies := sessionCookie + "; " + utils.CSRFCookie + "=" + csrfToken
+
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/person/session", "", map[string]string{"Cookie": cookies}); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("session answered with %d", resp.StatusCode)
+	}
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/person/sessions", "", map[string]string{"Cookie": cookies}); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("session list answered with %d", resp.StatusCode)
+	}
+
+	// State-changing requests need the CSRF token
+	if resp, _ := send(t, app, fiber.MethodPost, "/api/person/session/logout", "", map[string]string{"Cookie": cookies}); resp.StatusCode != fiber.StatusForbidden {
+		t.Fatalf("logout without CSRF token answered with %d", resp.StatusCode)
+	}
+	logout := map[string]string{"Cookie": cookies, utils.CSRFHeader: csrfToken}
+	if resp, _ := send(t, app, fiber.MethodPost, "/api/person/session/logout", "", logout); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("logout answered with %d", resp.StatusCode)
+	}
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/person/session", "", map[string]string{"Cookie": cookies}); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("logged out session answered with %d", resp.StatusCode)
+	}
+}
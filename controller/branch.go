@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"backend/database"
 	"backend/generic"
 	"backend/model"
 
@@ -17,9 +18,10 @@ func GetBranch(db *gorm.DB) fiber.Handler {
 			BranchName string `json:"branch_name"`
 		}
 
-		// Query the database for branch codes and names from the JSONB column
+		// Query the database for branch codes and names from the JSON column
 		if err := db.Table("branches").
-			Select("branch_data->>'branch_code' as branch_code,branch_data->>'branch_name' as branch_name").
+			Select(database.JSONText(db, "branch_data", "branch_code") + " as branch_code," +
+				database.JSONText(db, "branch_data", "branch_name") + " as branch_name").
 			Scan(&results).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch branches"})
 		}
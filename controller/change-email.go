@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"backend/config"
+	"backend/custom"
+	"backend/model"
+	"backend/utils"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// ChangeEmail starts moving the current user to a new email address. The current address
+// stays active until the link sent to the new one is followed.
+func ChangeEmail(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		var request model.ChangeEmailRequest
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("User not found", fiber.StatusNotFound))
+		}
+
+		// The current password is required so a stolen token cannot take over the account
+		if httpErr := verifyCurrentPassword(c, db, &user, request.Password); httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		if strings.EqualFold(request.NewEmail, user.Email) {
+			return custom.SendErrorResponse(c, custom.NewHttpError("New email must differ from the current one", fiber.StatusBadRequest))
+		}
+
+		token, err := utils.CreateEmailChange(db, user.ID, request.NewEmail)
+		switch {
+		case errors.Is(err, utils.ErrEmailTaken):
+			// Answer as if the change had started so registered addresses are not revealed,
+			// and let the owner of the address know instead
+			notice := "Someone tried to move another account to this email address. Your account has not been changed."
+			if err := utils.SendNotificationEmail(request.NewEmail, "Email change attempt", notice); err != nil {
+				log.Printf("Could not notify the owner of a taken email: %v", err)
+			}
+		case err != nil:
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not start email change", fiber.StatusInternalServerError))
+		default:
+			// Construct the confirmation link
+			confirmLink := publicLink(cfg, "/api/person/email/confirm", token)
+
+			// Send the confirmation email to the new address
+			emailBody := "Please confirm your new email address by clicking the link below."
+			if err := utils.MailtrapSendEmail(request.NewEmail, "Confirm your new email", emailBody, confirmLink); err != nil {
+				log.Printf("Could not send email change confirmation: %v", err)
+				return custom.SendErrorResponse(c, custom.NewHttpError("Could not send confirmation email", fiber.StatusInternalServerError))
+			}
+		}
+
+		// Let the owner of the current address know, in case they did not ask for this
+		notice := "A change of your account email to " + request.NewEmail + " was requested. If this was not you, reset your password right away."
+		if err := utils.SendNotificationEmail(user.Email, "Email change requested", notice); err != nil {
+			log.Printf("Could not notify user %d of the email change: %v", user.ID, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "A confirmation link has been sent to the new address, your current email stays active until it is confirmed",
+		})
+	}
+}
+
+// ConfirmEmailChange switches the account to the new address once its link is followed
+func ConfirmEmailChange(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		change, previousEmail, err := utils.ConfirmEmailChange(db, c.Query("token"))
+		if err != nil {
+			switch {
+			case errors.Is(err, utils.ErrInvalidEmailChangeToken):
+				return custom.SendErrorResponse(c, custom.NewHttpError("Invalid or expired confirmation token", fiber.StatusBadRequest))
+			case errors.Is(err, utils.ErrEmailTaken):
+				return custom.SendErrorResponse(c, custom.NewHttpError("Email already exists", fiber.StatusConflict))
+			}
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not change email", fiber.StatusInternalServerError))
+		}
+
+		// Tell the previous address that it no longer signs in to the account
+		notice := "The email of your account has been changed to " + change.NewEmail + ". If this was not you, contact support right away."
+		if err := utils.SendNotificationEmail(previousEmail, "Email changed", notice); err != nil {
+			log.Printf("Could not notify user %d of the email change: %v", change.UserID, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Email changed successfully",
+			"email":   change.NewEmail,
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package controller_test
+
+import (
+	"backend/model"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// mailTokenPattern finds the token of an emailed link
+var mailTokenPattern = regexp.MustCompile(`token=([0-9a-f]{32})`)
+
+// mailedToken returns the token of the only link mailed to the address
+func mailedToken(t *testing.T, mails *testMailServer, address string) string {
+	t.Helper()
+	messages := mails.mailsTo(t, address)
+	if len(messages) == 0 {
+		t.Fatalf("no mail sent to %s", address)
+	}
+	match := mailTokenPattern.FindStringSubmatch(messages[len(messages)-1])
+	if match == nil {
+		t.Fatalf("no link in the mail to %s: %s", address, messages[len(messages)-1])
+	}
+	return match[1]
+}
+
+func TestChangeEmail(t *testing.T) {
+	app, db := newTestApp(t)
+	mails := newTestMailServer(t)
+	user := newTestUser(t, db, "old@example.com", "password1")
+	token, _ := loginTokens(t, app, "old@example.com", "password1")
+
+	changeEmail := func(newEmail string) {
+		t.Helper()
+		body := fmt.Sprintf(`{"password":"password1","new_email":%q}`, newEmail)
+		if resp, body := send(t, app, fiber.MethodPost, "/api/person/email", body, bearer(token)); resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("email change answered with %d: %v", resp.StatusCode, body)
+		}
+	}
+	confirm := func(token string) int {
+		t.Helper()
+		resp, _ := send(t, app, fiber.MethodGet, "/api/person/email/confirm?token="+token, "", nil)
+		return resp.StatusCode
+	}
+
+	changeEmail("first@example.com")
+	firstToken := mailedToken(t, mails, "first@example.com")
+
+	// The old address is told about the request and keeps working until the change is confirmed
+	if notices := mails.mailsTo(t, "old@example.com"); len(notices) != 1 || !strings.Contains(notices[0], "first@example.com") {
+		t.Fatalf("got notices %q, want one naming the new address", notices)
+	}
+	loginTokens(t, app, "old@example.com", "password1")
+
+	// A new request revokes the earlier link
+	changeEmail("new@example.com")
+	newToken := mailedToken(t, mails, "new@example.com")
+	if status := confirm(firstToken); status != fiber.StatusBadRequest {
+		t.Fatalf("revoked link answered with %d", status)
+	}
+
+	if status := confirm(newToken); status != fiber.StatusOK {
+		t.Fatalf("confirmation answered with %d", status)
+	}
+	if status := confirm(newToken); status != fiber.StatusBadRequest {
+		t.Fatalf("second confirmation answered with %d", status)
+	}
+
+	if err := db.First(user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "new@example.com" || !user.IsVerified {
+		t.Fatalf("got email %q verified %v, want new@example.com verified", user.Email, user.IsVerified)
+	}
+	if notices := mails.mailsTo(t, "old@example.com"); len(notices) != 3 || !strings.Contains(notices[2], "has been changed") {
+		t.Fatalf("got notices %q, want a last one telling the change happened", notices)
+	}
+	loginTokens(t, app, "new@example.com", "password1")
+	if resp, _ := login(t, app, "old@example.com", "password1"); resp.StatusCode == fiber.StatusOK {
+		t.Fatal("the old address still logs in")
+	}
+}
+
+func TestChangeEmailTaken(t *testing.T) {
+	app, db := newTestApp(t)
+	mails := newTestMailServer(t)
+	user := newTestUser(t, db, "user@example.com", "password1")
+	newTestUser(t, db, "taken@example.com", "password1")
+	token, _ := loginTokens(t, app, "user@example.com", "password1")
+
+	change := func(newEmail string) map[string]any {
+		t.Helper()
+		body := fmt.Sprintf(`{"password":"password1","new_email":%q}`, newEmail)
+		resp, decoded := send(t, app, fiber.MethodPost, "/api/person/email", body, bearer(token))
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("email change to %s answered with %d: %v", newEmail, resp.StatusCode, decoded)
+		}
+		return decoded
+	}
+
+	taken := change("taken@example.com")
+	free := change("free@example.com")
+	if fmt.Sprint(taken) != fmt.Sprint(free) {
+		t.Fatalf("responses differ: %v and %v", taken, free)
+	}
+
+	// The owner of the taken address is warned and gets no link
+	notices := mails.mailsTo(t, "taken@example.com")
+	if len(notices) != 1 || !strings.Contains(notices[0], "tried") || mailTokenPattern.MatchString(notices[0]) {
+		t.Fatalf("got mails %q, want one warning", notices)
+	}
+
+	var changes int64
+	if err := db.Model(&model.EmailChange{}).Where("user_id = ? AND new_email = ?", user.ID, "taken@example.com").Count(&changes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if changes != 0 {
+		t.Fatalf("got %d changes to the taken address, want none", changes)
+	}
+}
+
+func TestChangeEmailWrongPassword(t *testing.T) {
+	app, db := newTestApp(t)
+	mails := newTestMailServer(t)
+	newTestUser(t, db, "user@example.com", "password1")
+	token, _ := loginTokens(t, app, "user@example.com", "password1")
+
+	body := `{"password":"password2","new_email":"new@example.com"}`
+	if resp, body := send(t, app, fiber.MethodPost, "/api/person/email", body, bearer(token)); resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("got status %d: %v", resp.StatusCode, body)
+	}
+	if sent := len(mails.mailsTo(t, "new@example.com")) + len(mails.mailsTo(t, "user@example.com")); sent != 0 {
+		t.Fatalf("got %d mails, want none", sent)
+	}
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"backend/custom"
+	"backend/model"
+	"backend/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// ChangePassword sets a new password for the current user and logs out their other sessions
+func ChangePassword(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		var request model.ChangePasswordRequest
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("User not found", fiber.StatusNotFound))
+		}
+
+		if httpErr := verifyCurrentPassword(c, db, &user, request.CurrentPassword); httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		if err := utils.ValidatePasswordChange(db, &user, request.NewPassword); err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		if err := utils.SetPassword(db, &user, request.NewPassword); err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not update password", fiber.StatusInternalServerError))
+		}
+		recordSecurityEvent(c, db, user.ID, user.Email, model.SecurityPasswordChange, "")
+
+		// Outstanding reset links would undo the change
+		if err := db.Model(&model.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			log.Printf("Could not invalidate reset tokens of user %d: %v", user.ID, err)
+		}
+
+		// Keep the session the change was made from
+		if _, err := utils.RevokeOtherSessions(db, user.ID, currentFamily(c)); err != nil {
+			log.Printf("Could not revoke other sessions of user %d: %v", user.ID, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Password changed successfully, other sessions have been logged out",
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// readinessTimeout bounds each dependency check of the readiness probe
+const readinessTimeout = 2 * time.Second
+
+// Healthz reports that the process is alive and serving requests. It checks no dependencies
+// so a database outage does not get the instance restarted.
+func Healthz() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "ok"})
+	}
+}
+
+// Readyz reports whether the instance can serve traffic: the database answers a ping, the
+// mail server accepts connections and the token store can be read. Any failing check
+// answers 503 so the orchestrator stops routing requests here. The probe is public, so only
+// the status of each check is returned and the details of failures are logged.
+func Readyz(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		ready := true
+		down := func(check string, err error) string {
+			ready = false
+			log.Printf("Readiness check %s failed: %v", check, err)
+			return "down"
+		}
+
+		// Database, with the pool usage logged when the ping fails
+		database := "up"
+		if sqlDB, err := db.DB(); err != nil {
+			database = down("database", err)
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+			start := time.Now()
+			err := sqlDB.PingContext(ctx)
+			cancel()
+			if err != nil {
+				stats := sqlDB.Stats()
+				database = down("database", fmt.Errorf("%w (after %s, %d open, %d in use, %d idle, %d max)",
+					err, time.Since(start).Round(time.Millisecond), stats.OpenConnections, stats.InUse, stats.Idle, stats.MaxOpenConnections))
+			}
+		}
+
+		// Mail transport, skipped when no SMTP server is configured
+		mail := "up"
+		if err := utils.CheckMailTransport(readinessTimeout); errors.Is(err, utils.ErrMailNotConfigured) {
+			mail = "disabled"
+		} else if err != nil {
+			mail = down("mail", err)
+		}
+
+		// Token store
+		tokenStore := "up"
+		if kind, err := utils.CheckTokenStore(); err != nil {
+			tokenStore = down("token_store", fmt.Errorf("%s: %w", kind, err))
+		}
+
+		status, code := "ready", fiber.StatusOK
+		if !ready {
+			status, code = "unavailable", fiber.StatusServiceUnavailable
+		}
+		return c.Status(code).JSON(fiber.Map{
+			"status": status,
+			"checks": fiber.Map{
+				"database":    fiber.Map{"status": database},
+				"mail":        fiber.Map{"status": mail},
+				"token_store": fiber.Map{"status": tokenStore},
+			},
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package controller_test
+
+import (
+	"backend/config"
+	"backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		mail       config.MailConfig
+		wantStatus int
+		wantMail   string
+	}{
+		{name: "mail disabled", wantStatus: fiber.StatusOK, wantMail: "disabled"},
+		{name: "mail unreachable", mail: config.MailConfig{Host: "127.0.0.1", Port: 1}, wantStatus: fiber.StatusServiceUnavailable, wantMail: "down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApp(t)
+			utils.SetMailConfig(tt.mail)
+			t.Cleanup(func() { utils.SetMailConfig(config.MailConfig{}) })
+
+			resp, body := send(t, app, fiber.MethodGet, "/readyz", "", nil)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %v", resp.StatusCode, tt.wantStatus, body)
+			}
+
+			checks, _ := body["checks"].(map[string]any)
+			want := map[string]string{"database": "up", "mail": tt.wantMail, "token_store": "up"}
+			for name, status := range want {
+				check, _ := checks[name].(map[string]any)
+				if len(check) != 1 || check["status"] != status {
+					t.Errorf("got check %s %v, want only the status %s", name, check, status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package controller_test
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/migrations"
+	"backend/model"
+	"backend/routes"
+	"backend/utils"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// newTestApp serves the routes against a migrated in-memory SQLite database private to the test
+func newTestApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	t.Helper()
+	cfg := config.Default(config.ProfileTest)
+	cfg.Auth.JWT.Secret = "test-secret"
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := database.Connect(config.DatabaseConfig{
+		Driver:  database.DriverSQLite,
+		Name:    fmt.Sprintf("file:%s?mode=memory&cache=shared", name),
+		Connect: config.ConnectConfig{Attempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SeedRoles(db, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.LoadSigningKeys(cfg.Auth.JWT); err != nil {
+		t.Fatal(err)
+	}
+	utils.SetLoginThrottleConfig(cfg.Auth.LoginThrottle)
+	utils.SetArgon2Params(cfg.Auth.Argon2)
+	utils.SetEmailVerificationConfig(cfg.Auth.EmailVerification)
+	utils.SetWebSessionConfig(cfg.Auth.Session)
+
+	app := fiber.New(fiber.Config{StructValidator: utils.Validator})
+	routes.SetupRoutes(app, db, cfg)
+	routes.ProtectedRoutes(app, db)
+	return app, db
+}
+
+// newTestUser creates a verified user with the default role and the given password
+func newTestUser(t *testing.T, db *gorm.DB, email string, password string) *model.User {
+	t.Helper()
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var role model.Role
+	if err := db.Where("name = ?", model.RoleUser).First(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{Name: "testuser", Age: 30, Email: email, Password: hash, IsVerified: true, Roles: []model.Role{role}}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// send makes a JSON request with the given headers and returns the response and its decoded body
+func send(t *testing.T, app *fiber.App, method string, path string, body string, headers map[string]string) (*http.Response, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	json.Unmarshal(data, &decoded)
+	return resp, decoded
+}
+
+// bearer returns the headers authenticating a request with the access token
+func bearer(token string) map[string]string {
+	return map[string]string{fiber.HeaderAuthorization: "Bearer " + token}
+}
+
+// login posts the credentials and returns the response and its decoded body
+func login(t *testing.T, app *fiber.App, email string, password string) (*http.Response, map[string]any) {
+	t.Helper()
+	return send(t, app, fiber.MethodPost, "/api/person/login", fmt.Sprintf(`{"email":%q,"password":%q}`, email, password), nil)
+}
+
+// grantRole adds the named role to the user
+func grantRole(t *testing.T, db *gorm.DB, user *model.User, name string) {
+	t.Helper()
+	var role model.Role
+	if err := db.Where("name = ?", name).First(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(user).Association("Roles").Append(&role); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loginTokens logs the user in and returns their access and refresh tokens
+func loginTokens(t *testing.T, app *fiber.App, email string, password string) (string, string) {
+	t.Helper()
+	resp, body := login(t, app, email, password)
+	accessToken, _ := body["token"].(string)
+	refreshToken, _ := body["refresh_token"].(string)
+	if resp.StatusCode != fiber.StatusOK || accessToken == "" {
+		t.Fatalf("login of %s answered with %d: %v", email, resp.StatusCode, body)
+	}
+	return accessToken, refreshToken
+}
+
+// testMailServer is an SMTP server keeping the messages it receives
+type testMailServer struct {
+	mu       sync.Mutex
+	messages map[string][]string // Decoded messages by recipient
+}
+
+// newTestMailServer starts an SMTP server on a free port and sends the test's mail through it
+func newTestMailServer(t *testing.T) *testMailServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testMailServer{messages: map[string][]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	utils.SetMailConfig(config.MailConfig{Host: addr.IP.String(), Port: addr.Port, From: "noreply@example.com"})
+	t.Cleanup(func() {
+		listener.Close()
+		utils.SetMailConfig(config.Default(config.ProfileTest).Mail)
+	})
+	return server
+}
+
+// serve answers one SMTP session, accepting every command
+func (s *testMailServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 test")
+	var recipients []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimSpace(line)
+		switch upper := strings.ToUpper(command); {
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			recipients = append(recipients, strings.Trim(command[len("RCPT TO:"):], " <>"))
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 Send the message")
+			data, err := textproto.NewReader(reader).ReadDotBytes()
+			if err != nil {
+				return
+			}
+			decoded, _ := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data)))
+			s.mu.Lock()
+			for _, recipient := range recipients {
+				s.messages[recipient] = append(s.messages[recipient], string(decoded))
+			}
+			s.mu.Unlock()
+			recipients = nil
+			reply("250 OK")
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// mailsTo waits for mail sent in the background and returns the messages received for the address
+func (s *testMailServer) mailsTo(t *testing.T, address string) []string {
+	t.Helper()
+	if err := utils.WaitBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages[address]
+}
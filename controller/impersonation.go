@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"backend/custom"
+	"backend/model"
+	"backend/utils"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// auditLogLimit caps how many audit log entries are returned at once
+const auditLogLimit = 100
+
+// Impersonate issues a short-lived token that lets an admin act as another user
+func Impersonate(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		actorID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		targetID, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest))
+		}
+
+		var target model.User
+		if err := db.First(&target, targetID).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("User not found", fiber.StatusNotFound))
+		}
+
+		token, err := utils.IssueImpersonationToken(db, actorID, target.ID)
+		if err != nil {
+			if errors.Is(err, utils.ErrCannotImpersonate) {
+				return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusForbidden))
+			}
+			log.Printf("Could not issue impersonation token for user %d: %v", target.ID, err)
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not generate token", fiber.StatusInternalServerError))
+		}
+
+		utils.RecordAudit(db, model.AuditLog{
+			ActorID: actorID,
+			UserID:  target.ID,
+			Action:  model.AuditImpersonationStart,
+			Method:  c.Method(),
+			Path:    c.OriginalURL(),
+			Status:  fiber.StatusOK,
+			IP:      c.IP(),
+		})
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":    "Impersonation token issued",
+			"token":      token,
+			"expires_in": int(utils.AccessTokenTTL.Seconds()),
+			"user_id":    target.ID,
+		})
+	}
+}
+
+// GetAuditLogs lists the most recent audit log entries, optionally filtered by user_id and actor_id
+func GetAuditLogs(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		query := db.Order("created_at DESC, id DESC").Limit(auditLogLimit)
+
+		for _, filter := range []string{"user_id", "actor_id"} {
+			value := c.Query(filter)
+			if value == "" {
+				continue
+			}
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return custom.SendErrorResponse(c, custom.NewHttpError("Invalid "+filter, fiber.StatusBadRequest))
+			}
+			query = query.Where(filter+" = ?", id)
+		}
+
+		var entries []model.AuditLog
+		if err := query.Find(&entries).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not retrieve audit log", fiber.StatusInternalServerError))
+		}
+
+		return c.JSON(entries)
+	}
+}
@@ -0,0 +1,14 @@
+package controller
+
+import (
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// JWKS publishes the public signing keys so other services can verify tokens
+func JWKS() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		return c.JSON(fiber.Map{"keys": utils.JWKS()})
+	}
+}
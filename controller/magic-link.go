@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"backend/config"
+	"backend/custom"
+	"backend/model"
+	"backend/utils"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// magicLinkCookiePath limits the browser cookie to the magic link endpoints
+const magicLinkCookiePath = "/api/person/login/magic"
+
+// RequestMagicLink emails a single-use login link that only works in the requesting browser
+func RequestMagicLink(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request model.MagicLinkRequest
+
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		// Same response whether or not the email exists
+		response := fiber.Map{
+			"message": "If the email is registered, a login link has been sent",
+		}
+
+		// Identify this browser, keeping an existing ID so earlier links stay usable
+		browserID := c.Cookies(utils.MagicLinkCookie)
+		if browserID == "" {
+			browserID = utils.NewMagicLinkBrowserID()
+		}
+		c.Cookie(&fiber.Cookie{
+			Name:     utils.MagicLinkCookie,
+			Value:    browserID,
+			Path:     magicLinkCookiePath,
+			MaxAge:   int(utils.MagicLinkTTL.Seconds()),
+			Secure:   c.Protocol() == "https",
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+
+		var user model.User
+		if err := db.Where("email = ?", request.Email).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(fiber.StatusOK).JSON(response)
+			}
+			err := custom.NewHttpError("Could not find user", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+
+		// Failures are only logged so the response does not tell registered addresses apart
+		token, err := utils.IssueMagicLink(db, user.ID, browserID)
+		if err != nil {
+			if !errors.Is(err, utils.ErrMagicLinkRateLimited) {
+				log.Printf("Could not create login link of user %d: %v", user.ID, err)
+			}
+			return c.Status(fiber.StatusOK).JSON(response)
+		}
+
+		// Construct the login link
+		loginLink := publicLink(cfg, "/api/person/login/magic/verify", token)
+
+		// Sent in the background so the response takes as long for unknown addresses
+		utils.RunInBackground(func() {
+			emailBody := "Click the link below to log in. It expires in 15 minutes and only works in the browser you requested it from."
+			if err := utils.MailtrapSendEmail(user.Email, "Your login link", emailBody, loginLink); err != nil {
+				log.Printf("Could not send login link email to user %d: %v", user.ID, err)
+			}
+		})
+
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// ConsumeMagicLink exchanges a login link for the same tokens a password login returns
+func ConsumeMagicLink(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := utils.ConsumeMagicLink(db, c.Query("token"), c.Cookies(utils.MagicLinkCookie))
+		if err != nil {
+			switch {
+			case errors.Is(err, utils.ErrInvalidMagicLink):
+				return custom.SendErrorResponse(c, custom.NewHttpError("Invalid or expired login link", fiber.StatusBadRequest))
+			case errors.Is(err, utils.ErrMagicLinkBrowser):
+				return custom.SendErrorResponse(c, custom.NewHttpError("Open the login link in the browser you requested it from", fiber.StatusForbidden))
+			}
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not use login link", fiber.StatusInternalServerError))
+		}
+
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid or expired login link", fiber.StatusBadRequest))
+		}
+
+		// Following the emailed link proves the address belongs to the user
+		if !user.IsVerified {
+			if err := db.Model(&user).Update("is_verified", true).Error; err != nil {
+				log.Printf("Could not mark user %d as verified: %v", user.ID, err)
+			}
+		}
+
+		// The browser cookie is no longer needed
+		c.Cookie(&fiber.Cookie{
+			Name:     utils.MagicLinkCookie,
+			Path:     magicLinkCookiePath,
+			Expires:  time.Unix(0, 0),
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+
+		return completeLogin(c, db, &user)
+	}
+}
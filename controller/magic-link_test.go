@@ -0,0 +1,47 @@
+package controller_test
+
+import (
+	"backend/model"
+	"backend/utils"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestRequestMagicLinkSameResponse(t *testing.T) {
+	app, db := newTestApp(t)
+	user := newTestUser(t, db, "user@example.com", "password1")
+
+	// No mail server is configured, sending fails in the background
+	request := func(email string) (int, map[string]any) {
+		resp, body := send(t, app, fiber.MethodPost, "/api/person/login/magic", fmt.Sprintf(`{"email":%q}`, email), nil)
+		if err := utils.WaitBackground(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, body
+	}
+
+	registeredStatus, registered := request("user@example.com")
+	unknownStatus, unknown := request("nobody@example.com")
+	// A second request is rate limited and must not stand out either
+	limitedStatus, limited := request("user@example.com")
+
+	for _, status := range []int{registeredStatus, unknownStatus, limitedStatus} {
+		if status != fiber.StatusOK {
+			t.Fatalf("got status %d, want %d", status, fiber.StatusOK)
+		}
+	}
+	if fmt.Sprint(registered) != fmt.Sprint(unknown) || fmt.Sprint(registered) != fmt.Sprint(limited) || registered["error"] != nil {
+		t.Fatalf("responses differ: %v, %v and %v", registered, unknown, limited)
+	}
+
+	var links int64
+	if err := db.Model(&model.MagicLink{}).Where("user_id = ?", user.ID).Count(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+	if links != 1 {
+		t.Fatalf("got %d login links, want 1", links)
+	}
+}
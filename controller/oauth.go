@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"backend/utils"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// Introspect reports whether a token is active and who and what it was issued for (RFC 7662)
+func Introspect(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "no-store")
+
+		token := c.FormValue("token")
+		if token == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_request"})
+		}
+
+		introspection, err := utils.IntrospectToken(db, token, c.FormValue("token_type_hint"))
+		if err != nil {
+			log.Printf("Could not introspect token: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server_error"})
+		}
+
+		return c.JSON(introspection)
+	}
+}
+
+// Revoke ends an access or refresh token (RFC 7009), answering 200 even for unknown tokens
+func Revoke(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		token := c.FormValue("token")
+		if token == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_request"})
+		}
+
+		if err := utils.RevokeToken(db, token); err != nil {
+			log.Printf("Could not revoke token: %v", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "server_error"})
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	}
+}
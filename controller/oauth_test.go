@@ -0,0 +1,207 @@
+package controller_test
+
+import (
+	"backend/model"
+	"backend/utils"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// newTestClient creates a service account with the token-client role and returns an API key
+// of it with the given scopes
+func newTestClient(t *testing.T, db *gorm.DB, scopes ...string) string {
+	t.Helper()
+	var role model.Role
+	if err := db.Where("name = ?", model.RoleTokenClient).First(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	account := model.ServiceAccount{Name: "client-" + strings.Join(scopes, "-"), Roles: []model.Role{role}}
+	if err := db.Create(&account).Error; err != nil {
+		t.Fatal(err)
+	}
+	key, prefix, hash := utils.GenerateAPIKey()
+	if err := db.Create(&model.APIKey{ServiceAccountID: &account.ID, Name: account.Name, Prefix: prefix, KeyHash: hash, Scopes: scopes}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// sendForm posts a form to an OAuth endpoint as the client with the given key and returns the
+// response and its decoded body
+func sendForm(t *testing.T, app *fiber.App, path string, form url.Values, key string) (int, map[string]any) {
+	t.Helper()
+	headers := map[string]string{fiber.HeaderContentType: fiber.MIMEApplicationForm}
+	if key != "" {
+		headers["X-API-Key"] = key
+	}
+	resp, body := send(t, app, fiber.MethodPost, path, form.Encode(), headers)
+	return resp.StatusCode, body
+}
+
+// introspect returns whether the token is active and the introspection response
+func introspect(t *testing.T, app *fiber.App, client string, token string) (bool, map[string]any) {
+	t.Helper()
+	status, body := sendForm(t, app, "/oauth/introspect", url.Values{"token": {token}}, client)
+	if status != fiber.StatusOK {
+		t.Fatalf("introspection answered with %d: %v", status, body)
+	}
+	return body["active"] == true, body
+}
+
+func TestIntrospect(t *testing.T) {
+	app, db := newTestApp(t)
+	client := newTestClient(t, db, model.PermissionTokenIntrospect)
+	user := newTestUser(t, db, "user@example.com", "password1")
+	accessToken, refreshToken := loginTokens(t, app, "user@example.com", "password1")
+
+	tests := []struct {
+		name       string
+		token      string
+		wantActive bool
+		wantType   string
+	}{
+		{name: "access token", token: accessToken, wantActive: true, wantType: utils.TokenTypeAccess},
+		{name: "refresh token", token: refreshToken, wantActive: true, wantType: utils.TokenTypeRefresh},
+		{name: "unknown token", token: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, body := introspect(t, app, client, tt.token)
+			if active != tt.wantActive {
+				t.Fatalf("got active %v, want %v: %v", active, tt.wantActive, body)
+			}
+			if !tt.wantActive {
+				// Inactive tokens reveal nothing else
+				if len(body) != 1 {
+					t.Fatalf("inactive token described as %v", body)
+				}
+				return
+			}
+			if body["token_type"] != tt.wantType || body["username"] != user.Email || body["sub"] == "" {
+				t.Fatalf("got %v, want a %s of %s", body, tt.wantType, user.Email)
+			}
+		})
+	}
+
+	// A rotated refresh token is no longer active
+	if resp, body := send(t, app, fiber.MethodPost, "/api/person/refresh", `{"refresh_token":"`+refreshToken+`"}`, nil); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("refresh answered with %d: %v", resp.StatusCode, body)
+	}
+	if active, body := introspect(t, app, client, refreshToken); active {
+		t.Fatalf("rotated refresh token is active: %v", body)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	tests := []struct {
+		name          string
+		revokeRefresh bool // Revoke the refresh token instead of the access token
+	}{
+		{name: "access token"},
+		{name: "refresh token ends its family", revokeRefresh: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			client := newTestClient(t, db, model.PermissionTokenIntrospect, model.PermissionTokenRevoke)
+			newTestUser(t, db, "user@example.com", "password1")
+			accessToken, refreshToken := loginTokens(t, app, "user@example.com", "password1")
+
+			revoked := accessToken
+			if tt.revokeRefresh {
+				revoked = refreshToken
+			}
+			if status, body := sendForm(t, app, "/oauth/revoke", url.Values{"token": {revoked}}, client); status != fiber.StatusOK {
+				t.Fatalf("revocation answered with %d: %v", status, body)
+			}
+
+			// Either way the access token stops working
+			if active, _ := introspect(t, app, client, accessToken); active {
+				t.Fatal("revoked access token is active")
+			}
+			if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(accessToken)); resp.StatusCode != fiber.StatusUnauthorized {
+				t.Fatalf("revoked access token answered with %d", resp.StatusCode)
+			}
+
+			// Revoking the access token leaves the refresh token usable
+			if active, _ := introspect(t, app, client, refreshToken); active == tt.revokeRefresh {
+				t.Fatalf("refresh token active is %v, want %v", active, !tt.revokeRefresh)
+			}
+		})
+	}
+
+	t.Run("unknown token", func(t *testing.T) {
+		app, db := newTestApp(t)
+		client := newTestClient(t, db, model.PermissionTokenRevoke)
+		if status, body := sendForm(t, app, "/oauth/revoke", url.Values{"token": {"unknown"}}, client); status != fiber.StatusOK {
+			t.Fatalf("revocation of an unknown token answered with %d: %v", status, body)
+		}
+	})
+}
+
+func TestOAuthClientAuth(t *testing.T) {
+	app, db := newTestApp(t)
+	introspector := newTestClient(t, db, model.PermissionTokenIntrospect)
+	revoker := newTestClient(t, db, model.PermissionTokenRevoke)
+	newTestUser(t, db, "user@example.com", "password1")
+	accessToken, _ := loginTokens(t, app, "user@example.com", "password1")
+
+	// Basic credentials carry the key prefix as client_id and the rest as client_secret
+	separator := strings.LastIndex(introspector, "_")
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(url.QueryEscape(introspector[:separator])+":"+url.QueryEscape(introspector[separator+1:])))
+	wrongBasic := "Basic " + base64.StdEncoding.EncodeToString([]byte(url.QueryEscape(introspector[:separator])+":wrong"))
+
+	tests := []struct {
+		name       string
+		path       string
+		headers    map[string]string
+		form       url.Values
+		wantStatus int
+		wantError  string
+	}{
+		{name: "API key header", path: "/oauth/introspect", headers: map[string]string{"X-API-Key": introspector}, wantStatus: fiber.StatusOK},
+		{name: "basic credentials", path: "/oauth/introspect", headers: map[string]string{fiber.HeaderAuthorization: basic}, wantStatus: fiber.StatusOK},
+		{name: "wrong client secret", path: "/oauth/introspect", headers: map[string]string{fiber.HeaderAuthorization: wrongBasic}, wantStatus: fiber.StatusUnauthorized, wantError: "invalid_client"},
+		{name: "no credentials", path: "/oauth/introspect", wantStatus: fiber.StatusUnauthorized, wantError: "invalid_client"},
+		{name: "user access token", path: "/oauth/introspect", headers: bearer(accessToken), wantStatus: fiber.StatusUnauthorized, wantError: "invalid_client"},
+		{name: "key without the introspect scope", path: "/oauth/introspect", headers: map[string]string{"X-API-Key": revoker}, wantStatus: fiber.StatusForbidden},
+		{name: "key without the revoke scope", path: "/oauth/revoke", headers: map[string]string{"X-API-Key": introspector}, wantStatus: fiber.StatusForbidden},
+		{name: "missing token", path: "/oauth/introspect", headers: map[string]string{"X-API-Key": introspector}, form: url.Values{}, wantStatus: fiber.StatusBadRequest, wantError: "invalid_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := tt.form
+			if form == nil {
+				form = url.Values{"token": {accessToken}}
+			}
+			headers := map[string]string{fiber.HeaderContentType: fiber.MIMEApplicationForm}
+			for name, value := range tt.headers {
+				headers[name] = value
+			}
+
+			resp, body := send(t, app, fiber.MethodPost, tt.path, form.Encode(), headers)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %v", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantError != "" && body["error"] != tt.wantError {
+				t.Fatalf("got error %v, want %q", body["error"], tt.wantError)
+			}
+			if resp.StatusCode == fiber.StatusUnauthorized && resp.Header.Get(fiber.HeaderWWWAuthenticate) == "" {
+				t.Fatal("unauthorized response without WWW-Authenticate")
+			}
+		})
+	}
+
+	// The checks above must not have revoked the token
+	if active, _ := introspect(t, app, introspector, accessToken); !active {
+		t.Fatal("access token is no longer active")
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"backend/custom"
+	"backend/utils"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// oidcCookiePath limits the state cookie to the OIDC endpoints
+const oidcCookiePath = "/api/person/oidc"
+
+// OIDCLogin sends the user to the identity provider to sign in
+func OIDCLogin(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		provider, err := utils.GetOIDCProvider(c.Params("provider"))
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Unknown identity provider", fiber.StatusNotFound))
+		}
+
+		authURL, state, err := utils.StartOIDCLogin(db, provider)
+		if err != nil {
+			log.Printf("Could not start login with %s: %v", provider.Name, err)
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not reach identity provider", fiber.StatusBadGateway))
+		}
+
+		// Bind the login to this browser, Lax so the cookie survives the provider's redirect back
+		c.Cookie(&fiber.Cookie{
+			Name:     utils.OIDCStateCookie,
+			Value:    state,
+			Path:     oidcCookiePath,
+			MaxAge:   int(utils.OIDCLoginTTL.Seconds()),
+			Secure:   c.Protocol() == "https",
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+
+		return c.Redirect().Status(fiber.StatusFound).To(authURL)
+	}
+}
+
+// OIDCCallback completes a provider sign-in and logs in the linked user
+func OIDCCallback(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		provider, err := utils.GetOIDCProvider(c.Params("provider"))
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Unknown identity provider", fiber.StatusNotFound))
+		}
+
+		// The provider reports denied or failed sign-ins in the error parameter
+		if providerError := c.Query("error"); providerError != "" {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Sign-in was not completed: "+providerError, fiber.StatusUnauthorized))
+		}
+
+		state, code := c.Query("state"), c.Query("code")
+		if state == "" || code == "" {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Missing state or code", fiber.StatusBadRequest))
+		}
+
+		browserState := c.Cookies(utils.OIDCStateCookie)
+
+		// The state cookie is single use like the state
+		c.Cookie(&fiber.Cookie{
+			Name:     utils.OIDCStateCookie,
+			Path:     oidcCookiePath,
+			Expires:  time.Unix(0, 0),
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+
+		claims, err := utils.FinishOIDCLogin(db, provider, state, browserState, code)
+		if err != nil {
+			switch {
+			case errors.Is(err, utils.ErrInvalidOIDCState):
+				return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+			case errors.Is(err, utils.ErrInvalidIDToken):
+				return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+			}
+			log.Printf("Could not complete login with %s: %v", provider.Name, err)
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not complete sign-in with identity provider", fiber.StatusBadGateway))
+		}
+
+		user, err := utils.LinkOIDCIdentity(db, provider.Name, claims)
+		if err != nil {
+			switch {
+			case errors.Is(err, utils.ErrOIDCEmailNotVerified), errors.Is(err, utils.ErrOIDCNoAccount):
+				return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusForbidden))
+			}
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not link identity", fiber.StatusInternalServerError))
+		}
+
+		return completeLogin(c, db, user)
+	}
+}
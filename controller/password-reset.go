@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"backend/config"
+	"backend/custom"
+	"backend/model"
+	"backend/utils"
+	"backend/views"
+	"log"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// passwordResetTTL is how long an emailed reset link stays valid
+const passwordResetTTL = time.Hour
+
+// ForgotPassword emails a single-use password reset link
+func ForgotPassword(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request model.ForgotPasswordRequest
+
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		// Same response whether or not the email exists
+		response := fiber.Map{
+			"message": "If the email is registered, a password reset link has been sent",
+		}
+
+		// Limit the emails sent to one address or from one IP, registered or not
+		wait, err := utils.CheckPasswordResetThrottle(db, request.Email, c.IP())
+		if err != nil {
+			err := custom.NewHttpError("Could not check password reset requests", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+		if wait > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			err := custom.NewHttpError("Too many password reset requests, please try again later", fiber.StatusTooManyRequests)
+			return custom.SendErrorResponse(c, err)
+		}
+		if _, err := utils.RecordPasswordResetRequest(db, request.Email, c.IP()); err != nil {
+			log.Printf("Could not record password reset request: %v", err)
+		}
+
+		var user model.User
+		if err := db.Where("email = ?", request.Email).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(fiber.StatusOK).JSON(response)
+			}
+			err := custom.NewHttpError("Could not find user", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+
+		// Generate a reset token, only its hash is stored. Failures are only logged so the
+		// response does not tell registered addresses apart.
+		resetToken := utils.GenerateVerificationToken()
+		reset := model.PasswordReset{
+			UserID:    user.ID,
+			TokenHash: utils.TokenKey(resetToken),
+			ExpiresAt: time.Now().Add(passwordResetTTL),
+		}
+		if err := db.Create(&reset).Error; err != nil {
+			log.Printf("Could not create password reset of user %d: %v", user.ID, err)
+			return c.Status(fiber.StatusOK).JSON(response)
+		}
+
+		// Construct the link to the page asking for the new password
+		resetLink := passwordResetLink(cfg, resetToken)
+
+		// Sent in the background so the response takes as long for unknown addresses
+		utils.RunInBackground(func() {
+			emailBody := "A password reset was requested for your account. The link expires in one hour."
+			if err := utils.MailtrapSendEmail(user.Email, "Password Reset", emailBody, resetLink); err != nil {
+				log.Printf("Could not send password reset email to user %d: %v", user.ID, err)
+			}
+		})
+
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// passwordResetLink builds the emailed link to the page that asks for the new password and
+// submits it with the token to POST /api/person/reset-password, by default the page served by
+// ResetPasswordPage
+func passwordResetLink(cfg *config.Config, token string) string {
+	page := cfg.Server.ResetURL
+	if page == "" {
+		page = strings.TrimSuffix(cfg.Server.PublicURL, "/") + "/reset-password"
+	}
+	separator := "?"
+	if strings.Contains(page, "?") {
+		separator = "&"
+	}
+	return page + separator + "token=" + url.QueryEscape(token)
+}
+
+// ResetPasswordPage serves the form the default reset link opens
+func ResetPasswordPage() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		page, err := views.Files.ReadFile("reset-password.html")
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Page not found", fiber.StatusNotFound))
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(page)
+	}
+}
+
+// ResetPassword sets a new password using a reset token and logs the user out everywhere
+func ResetPassword(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request model.ResetPasswordRequest
+
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		invalidToken := custom.NewHttpError("Invalid or expired reset token", fiber.StatusBadRequest)
+
+		var reset model.PasswordReset
+		if err := db.Where("token_hash = ?", utils.TokenKey(request.Token)).First(&reset).Error; err != nil {
+			return custom.SendErrorResponse(c, invalidToken)
+		}
+		if reset.UsedAt != nil || reset.ExpiresAt.Before(time.Now()) {
+			return custom.SendErrorResponse(c, invalidToken)
+		}
+
+		var user model.User
+		if err := db.First(&user, reset.UserID).Error; err != nil {
+			return custom.SendErrorResponse(c, invalidToken)
+		}
+
+		// Check the new password before the token is used up, so the user can try again
+		if err := utils.ValidatePasswordChange(db, &user, request.Password); err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		// Mark the token as used, losing a concurrent race means it was already consumed
+		result := db.Model(&model.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			err := custom.NewHttpError("Could not reset password", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+		if result.RowsAffected == 0 {
+			return custom.SendErrorResponse(c, invalidToken)
+		}
+
+		// Hash and store the new password
+		if err := utils.SetPassword(db, &user, request.Password); err != nil {
+			err := custom.NewHttpError("Could not update password", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+		recordSecurityEvent(c, db, user.ID, user.Email, model.SecurityPasswordReset, "")
+
+		// Invalidate any other outstanding reset links for the user
+		if err := db.Model(&model.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", reset.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			log.Printf("Could not invalidate reset tokens of user %d: %v", reset.UserID, err)
+		}
+
+		// Log the user out everywhere
+		if err := utils.RevokeUserTokens(db, reset.UserID); err != nil {
+			err := custom.NewHttpError("Could not revoke active tokens", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Password reset successfully, please log in with your new password",
+		})
+	}
+}
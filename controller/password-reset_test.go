@@ -0,0 +1,130 @@
+package controller_test
+
+import (
+	"backend/model"
+	"backend/utils"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestForgotPasswordSameResponse(t *testing.T) {
+	// Each address is asked for from a fresh app, the reset requests of one IP are throttled
+	forgot := func(email string) (body map[string]any, resets int64) {
+		t.Run(email, func(t *testing.T) {
+			body, resets = forgotPassword(t, email)
+		})
+		return body, resets
+	}
+
+	registered, resets := forgot("user@example.com")
+	if resets != 1 {
+		t.Fatalf("got %d reset tokens, want 1", resets)
+	}
+	unknown, _ := forgot("nobody@example.com")
+	if fmt.Sprint(registered) != fmt.Sprint(unknown) || registered["error"] != nil {
+		t.Fatalf("responses differ: %v and %v", registered, unknown)
+	}
+}
+
+// forgotPassword asks for a reset link for email on an app with one registered user and returns
+// the response and the number of reset tokens of that user
+func forgotPassword(t *testing.T, email string) (map[string]any, int64) {
+	app, db := newTestApp(t)
+	user := newTestUser(t, db, "user@example.com", "password1")
+
+	// No mail server is configured, sending fails in the background
+	_, body := send(t, app, fiber.MethodPost, "/api/person/forgot-password", fmt.Sprintf(`{"email":%q}`, email), nil)
+	if err := utils.WaitBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var resets int64
+	if err := db.Model(&model.PasswordReset{}).Where("user_id = ?", user.ID).Count(&resets).Error; err != nil {
+		t.Fatal(err)
+	}
+	return body, resets
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiresIn  time.Duration
+		used       bool
+		token      string // Sent instead of the stored token when set
+		wantStatus int
+	}{
+		{name: "valid token", expiresIn: time.Hour, wantStatus: fiber.StatusOK},
+		{name: "expired token", expiresIn: -time.Minute, wantStatus: fiber.StatusBadRequest},
+		{name: "used token", expiresIn: time.Hour, used: true, wantStatus: fiber.StatusBadRequest},
+		{name: "unknown token", expiresIn: time.Hour, token: "unknown", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			user := newTestUser(t, db, "user@example.com", "password1")
+			accessToken, refreshToken := loginTokens(t, app, "user@example.com", "password1")
+
+			token := utils.GenerateVerificationToken()
+			reset := model.PasswordReset{UserID: user.ID, TokenHash: utils.TokenKey(token), ExpiresAt: time.Now().Add(tt.expiresIn)}
+			if tt.used {
+				now := time.Now()
+				reset.UsedAt = &now
+			}
+			if err := db.Create(&reset).Error; err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				token = tt.token
+			}
+
+			body := fmt.Sprintf(`{"token":%q,"password":"newpassword2"}`, token)
+			resp, result := send(t, app, fiber.MethodPost, "/api/person/reset-password", body, nil)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %v", resp.StatusCode, tt.wantStatus, result)
+			}
+
+			wantPassword, wantSession := "password1", fiber.StatusOK
+			if tt.wantStatus == fiber.StatusOK {
+				wantPassword, wantSession = "newpassword2", fiber.StatusUnauthorized
+
+				// The link only works once
+				if resp, _ := send(t, app, fiber.MethodPost, "/api/person/reset-password", body, nil); resp.StatusCode != fiber.StatusBadRequest {
+					t.Fatalf("second reset answered with %d", resp.StatusCode)
+				}
+			}
+
+			// A reset logs the user out everywhere, a rejected one changes nothing
+			if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(accessToken)); resp.StatusCode != wantSession {
+				t.Fatalf("access token answered with %d, want %d", resp.StatusCode, wantSession)
+			}
+			if resp, _ := send(t, app, fiber.MethodPost, "/api/person/refresh", fmt.Sprintf(`{"refresh_token":%q}`, refreshToken), nil); resp.StatusCode != wantSession {
+				t.Fatalf("refresh token answered with %d, want %d", resp.StatusCode, wantSession)
+			}
+			loginTokens(t, app, "user@example.com", wantPassword)
+		})
+	}
+}
+
+func TestResetPasswordPage(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/reset-password?token=abc", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(page), "/api/person/reset-password") {
+		t.Fatalf("page answered with %d: %s", resp.StatusCode, page)
+	}
+}
@@ -1,18 +1,37 @@
 package controller
 
 import (
+	"backend/custom"
 	"backend/generic"
 	"backend/model"
+	"backend/utils"
+	"errors"
+	"log"
 
 	"github.com/gofiber/fiber/v3"
 	"gorm.io/gorm"
 )
 
+// CreatePerson uses the generic CreateResource function to create a user with their account
+// details and history. The password must follow the policy and only its hash is stored.
 func CreatePerson(db *gorm.DB) fiber.Handler {
 	var user model.User
 	var account_detail model.AccountDetail
 	var history model.History
-	return generic.CreateResource[model.User](db, &user, &account_detail, &history)
+	return generic.CreateResourceWithHook(db, &user, preparePerson, &account_detail, &history)
+}
+
+// preparePerson checks the password of a new user against the policy and replaces it with its hash
+func preparePerson(user *model.User) *custom.HttpError {
+	if err := utils.ValidatePassword(user.Password); err != nil {
+		return custom.NewHttpError(err.Error(), fiber.StatusBadRequest)
+	}
+	hash, err := utils.HashPassword(user.Password)
+	if err != nil {
+		return custom.NewHttpError("Could not hash password", fiber.StatusInternalServerError)
+	}
+	user.Password = hash
+	return nil
 }
 
 // GetAllPersons uses the generic GetAllResources function for retrieving all users
@@ -25,12 +44,37 @@ func GetPersonByID(db *gorm.DB) fiber.Handler {
 	return generic.GetResourceByID[model.User](db, []string{"AccountDetail", "History"})
 }
 
-// UpdatePerson uses the generic UpdateResource function for updating a user
+// UpdatePerson uses the generic UpdateResource function for updating a user. The email,
+// password and verification status have their own flows and are never updated here.
 func UpdatePerson(db *gorm.DB) fiber.Handler {
 	var person model.User
-	return generic.UpdateResource(db, &person)
+	return generic.UpdateResource(db, &person, "Email", "Password", "IsVerified")
 }
 
+// DeletePerson removes a user with their roles and every row they own, and logs them out of
+// every session
 func DeletePerson(db *gorm.DB) fiber.Handler {
-	return generic.DeleteResource[model.User](db, &model.AccountDetail{}, &model.History{})
+	return func(c fiber.Ctx) error {
+		userID, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest))
+		}
+
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return custom.SendErrorResponse(c, custom.NewHttpError("User not found", fiber.StatusNotFound))
+			}
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not find user", fiber.StatusInternalServerError))
+		}
+
+		if err := utils.DeleteUser(db, &user); err != nil {
+			log.Printf("Could not delete user %d: %v", user.ID, err)
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not delete user", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "User deleted successfully",
+		})
+	}
 }
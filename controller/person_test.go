@@ -0,0 +1,142 @@
+package controller_test
+
+import (
+	"backend/model"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestDeletePerson(t *testing.T) {
+	app, db := newTestApp(t)
+	admin := newTestUser(t, db, "admin@example.com", "password1")
+	grantRole(t, db, admin, model.RoleAdmin)
+	user := newTestUser(t, db, "user@example.com", "password1")
+	if err := db.Create(&model.AccountDetail{UserID: user.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.History{UserID: user.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	adminToken, _ := loginTokens(t, app, "admin@example.com", "password1")
+	userToken, refreshToken := loginTokens(t, app, "user@example.com", "password1")
+
+	path := fmt.Sprintf("/api/person/%d", user.ID)
+	if resp, body := send(t, app, fiber.MethodDelete, path, "", bearer(adminToken)); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("delete answered with %d: %v", resp.StatusCode, body)
+	}
+	if resp, _ := send(t, app, fiber.MethodDelete, path, "", bearer(adminToken)); resp.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("second delete answered with %d", resp.StatusCode)
+	}
+
+	// The deleted user is logged out everywhere
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(userToken)); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("access token of the deleted user answered with %d", resp.StatusCode)
+	}
+	if resp, _ := send(t, app, fiber.MethodPost, "/api/person/refresh", fmt.Sprintf(`{"refresh_token":%q}`, refreshToken), nil); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("refresh token of the deleted user answered with %d", resp.StatusCode)
+	}
+
+	remaining := map[string]string{
+		"users":           "id = ?",
+		"user_roles":      "user_id = ?",
+		"account_details": "user_id = ?",
+		"histories":       "user_id = ?",
+		"refresh_tokens":  "user_id = ?",
+		"sessions":        "user_id = ?",
+	}
+	for table, condition := range remaining {
+		var count int64
+		if err := db.Table(table).Where(condition, user.ID).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%d rows of the deleted user left in %s", count, table)
+		}
+	}
+
+	// The admin is unaffected
+	if resp, _ := send(t, app, fiber.MethodGet, "/api/protected/", "", bearer(adminToken)); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("admin token answered with %d", resp.StatusCode)
+	}
+}
+
+func TestPersonRoutesRequirePermission(t *testing.T) {
+	app, db := newTestApp(t)
+	newTestUser(t, db, "user@example.com", "password1")
+	admin := newTestUser(t, db, "admin@example.com", "password1")
+	grantRole(t, db, admin, model.RoleAdmin)
+	userToken, _ := loginTokens(t, app, "user@example.com", "password1")
+	adminToken, _ := loginTokens(t, app, "admin@example.com", "password1")
+
+	path := fmt.Sprintf("/api/person/%d", admin.ID)
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: fiber.MethodGet, path: "/api/person/"},
+		{method: fiber.MethodGet, path: path},
+		{method: fiber.MethodGet, path: "/api/person/excel"},
+		{method: fiber.MethodPost, path: "/api/person/", body: `{"name":"newperson1","age":30,"email":"new@example.com","password":"password1"}`},
+		{method: fiber.MethodPut, path: path, body: `{"name":"renamed123","age":40}`},
+		{method: fiber.MethodDelete, path: path},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if resp, body := send(t, app, tt.method, tt.path, tt.body, bearer(userToken)); resp.StatusCode != fiber.StatusForbidden {
+				t.Fatalf("plain user answered with %d: %v", resp.StatusCode, body)
+			}
+		})
+	}
+
+	// The permission check lets the admin through to the handler
+	if resp, body := send(t, app, fiber.MethodDelete, "/api/person/9999", "", bearer(adminToken)); resp.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("admin answered with %d: %v", resp.StatusCode, body)
+	}
+
+	var count int64
+	if err := db.Model(&model.User{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("got %d users, want the 2 unchanged ones", count)
+	}
+}
+
+func TestUpdatePerson(t *testing.T) {
+	app, db := newTestApp(t)
+	admin := newTestUser(t, db, "admin@example.com", "password1")
+	grantRole(t, db, admin, model.RoleAdmin)
+	user := newTestUser(t, db, "user@example.com", "password1")
+	adminToken, _ := loginTokens(t, app, "admin@example.com", "password1")
+
+	var adminRole model.Role
+	if err := db.Where("name = ?", model.RoleAdmin).First(&adminRole).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the name and age are taken, the email, password, verification and roles have their own flows
+	body := fmt.Sprintf(`{"name":"renamed12","age":40,"email":"other@example.com","password":"password2","is_verified":false,"roles":[{"id":%d,"name":%q}]}`, adminRole.ID, adminRole.Name)
+	path := fmt.Sprintf("/api/person/%d", user.ID)
+	if resp, body := send(t, app, fiber.MethodPut, path, body, bearer(adminToken)); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update answered with %d: %v", resp.StatusCode, body)
+	}
+
+	var got model.User
+	if err := db.Preload("Roles").First(&got, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "renamed12" || got.Age != 40 {
+		t.Fatalf("got name %q and age %d, want the update", got.Name, got.Age)
+	}
+	if got.Email != "user@example.com" || got.Password != user.Password || !got.IsVerified {
+		t.Fatalf("protected fields changed: %+v", got)
+	}
+	if len(got.Roles) != 1 || got.Roles[0].Name != model.RoleUser {
+		t.Fatalf("got roles %+v, want only %s", got.Roles, model.RoleUser)
+	}
+}
@@ -1,18 +1,24 @@
 package controller
 
 import (
+	"backend/config"
 	"backend/custom" // Import your custom utility package
 	"backend/model"
 	"backend/utils" // Import your email utility
+	"errors"
 	"log"
 	"math/rand"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"gorm.io/gorm"
 )
 
+// registeredMessage answers a registration, also when the address is already taken
+const registeredMessage = "Registered successfully, please check your email to verify your account"
+
 // RegisterUser handles the registration of a new user
-func RegisterUser(db *gorm.DB) fiber.Handler {
+func RegisterUser(db *gorm.DB, cfg *config.Config) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		var user model.User
 
@@ -35,16 +41,14 @@ func RegisterUser(db *gorm.DB) fiber.Handler {
 			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
 		}
 
-		// Check if the user already exists
-		var existingUser model.User
-		if err := db.Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
-			err := custom.NewHttpError("Email already exists", fiber.StatusConflict)
-			return custom.SendErrorResponse(c, err)
-		}
+		// Accounts always start unverified, whatever the request says
+		user.IsVerified = false
+		user.CreatedAt = time.Time{}
 
-		// Generate a verification token
-		verificationToken := utils.GenerateVerificationToken() // Implement this function to create a secure token
-		user.VerificationToken = verificationToken
+		// Check the password against the password policy
+		if err := utils.ValidatePassword(user.Password); err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
 
 		// Use the utility function to hash the password
 		hashedPassword, err := utils.HashPassword(user.Password)
@@ -54,6 +58,21 @@ func RegisterUser(db *gorm.DB) fiber.Handler {
 		}
 		user.Password = hashedPassword // Store the hashed password
 
+		// A taken address gets the usual answer so registered addresses are not revealed,
+		// and its owner is told instead
+		var existingUser model.User
+		if err := db.Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
+			utils.RunInBackground(func() {
+				notice := "Someone tried to register a new account with this email address. If this was you, log in or reset your password instead."
+				if err := utils.SendNotificationEmail(existingUser.Email, "Registration attempt", notice); err != nil {
+					log.Printf("Could not notify user %d of a registration attempt: %v", existingUser.ID, err)
+				}
+			})
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"message": registeredMessage,
+			})
+		}
+
 		// Create random balances
 		balances := make([]float64, 3)
 		val1 := rand.Float64() * 100 // Random value between 0 and 100
@@ -63,34 +82,58 @@ func RegisterUser(db *gorm.DB) fiber.Handler {
 		accountDetail := model.AccountDetail{Balance: balances[0]} // Default balance
 		user.AccountDetail = accountDetail
 
-		// Insert the new user into the database
-		if err := db.Create(&user).Error; err != nil {
-			err := custom.NewHttpError("Could not create user", fiber.StatusInternalServerError)
+		// Roles are never taken from the request, new users get the default role
+		var defaultRole model.Role
+		if err := db.Where("name = ?", model.RoleUser).First(&defaultRole).Error; err != nil {
+			err := custom.NewHttpError("Could not assign default role", fiber.StatusInternalServerError)
 			return custom.SendErrorResponse(c, err)
 		}
-
-		// Construct the verification link
-		verificationLink := "http://127.0.0.1:3000/api/person/verify?token=" + verificationToken // Replace with your actual domain
-
-		// Send the verification email
-		emailBody := "Please verify your email by clicking the following link: " + verificationLink
-		if err := utils.GoogleSendEmail(user.Email, "Email Verification", emailBody, verificationLink); err != nil {
-			log.Printf("Could not send verification email: %v", err)
-			return custom.SendErrorResponse(c, custom.NewHttpError("Could not send verification email", fiber.StatusInternalServerError))
+		user.Roles = []model.Role{defaultRole}
+
+		// Insert the user, its history entry and verification token together, so a failure
+		// leaves no partly registered account behind
+		var verificationToken string
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Insert the new user into the database
+			if err := tx.Create(&user).Error; err != nil {
+				return custom.NewHttpError("Could not create user", fiber.StatusInternalServerError)
+			}
+
+			// Log the action in the history table
+			historyEntry := model.History{
+				UserID: user.ID,
+				Action: "User created with name: " + user.Name,
+			}
+			if err := tx.Create(&historyEntry).Error; err != nil {
+				return custom.NewHttpError("Could not log history entry", fiber.StatusInternalServerError)
+			}
+
+			token, err := utils.CreateEmailVerification(tx, user.ID)
+			if err != nil {
+				return custom.NewHttpError("Could not create verification token", fiber.StatusInternalServerError)
+			}
+			verificationToken = token
+			return nil
+		})
+		if err != nil {
+			var httpErr *custom.HttpError
+			if !errors.As(err, &httpErr) {
+				httpErr = custom.NewHttpError("Could not create user", fiber.StatusInternalServerError)
+			}
+			return custom.SendErrorResponse(c, httpErr)
 		}
 
-		// Log the action in the history table
-		historyEntry := model.History{
-			UserID: user.ID,
-			Action: "User created with name: " + user.Name,
-		}
-		if err := db.Create(&historyEntry).Error; err != nil {
-			err := custom.NewHttpError("Could not log history entry", fiber.StatusInternalServerError)
-			return custom.SendErrorResponse(c, err)
+		// Send the verification email only once the account is committed. The account exists
+		// whatever happens here, so a failure asks for a new email instead of failing the request.
+		if err := mailVerificationLink(cfg, &user, verificationToken); err != nil {
+			log.Printf("Could not send verification email: %v", err)
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"message": "Registered successfully, but the verification email could not be sent, please request a new one later",
+			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"message": "Registered successfully, please check your email to verify your account",
+			"message": registeredMessage,
 		})
 	}
 }
@@ -0,0 +1,48 @@
+package controller_test
+
+import (
+	"backend/model"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestRegisterTakenEmail(t *testing.T) {
+	app, db := newTestApp(t)
+	mails := newTestMailServer(t)
+	newTestUser(t, db, "taken@example.com", "password1")
+
+	register := func(email string) map[string]any {
+		t.Helper()
+		body := fmt.Sprintf(`{"name":"newperson","age":30,"email":%q,"password":"password1"}`, email)
+		resp, decoded := send(t, app, fiber.MethodPost, "/api/person/register", body, nil)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("registration of %s answered with %d: %v", email, resp.StatusCode, decoded)
+		}
+		return decoded
+	}
+
+	taken := register("taken@example.com")
+	free := register("free@example.com")
+	if fmt.Sprint(taken) != fmt.Sprint(free) {
+		t.Fatalf("responses differ: %v and %v", taken, free)
+	}
+
+	// The owner of the taken address is told, the new address gets its verification link
+	if notices := mails.mailsTo(t, "taken@example.com"); len(notices) != 1 || !strings.Contains(notices[0], "tried to register") {
+		t.Fatalf("got mails %q, want one notice", notices)
+	}
+	if links := mails.mailsTo(t, "free@example.com"); len(links) != 1 || !mailTokenPattern.MatchString(links[0]) {
+		t.Fatalf("got mails %q, want one verification link", links)
+	}
+
+	var users int64
+	if err := db.Model(&model.User{}).Where("email = ?", "taken@example.com").Count(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if users != 1 {
+		t.Fatalf("got %d accounts with the taken address, want 1", users)
+	}
+}
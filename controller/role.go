@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"backend/custom"
+	"backend/generic"
+	"backend/model"
+	"backend/utils"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// GetRoles lists the roles with their permissions
+func GetRoles(db *gorm.DB) fiber.Handler {
+	return generic.GetAllResources[model.Role](db, []string{"Permissions"})
+}
+
+// AssignRole grants a role to a user
+func AssignRole(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var assignment model.RoleAssignment
+		if err := c.Bind().Body(&assignment); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		user, role, httpErr := findUserAndRole(db, c.Params("id"), assignment.Role)
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		if err := db.Model(user).Association("Roles").Append(role); err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not assign role", fiber.StatusInternalServerError))
+		}
+
+		// Drop the user's access tokens so the new role is picked up on refresh
+		if err := utils.DeleteUserTokens(user.ID); err != nil {
+			log.Printf("Could not remove tokens of user %d: %v", user.ID, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Role assigned successfully",
+		})
+	}
+}
+
+// RemoveRole revokes a role from a user
+func RemoveRole(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		user, role, httpErr := findUserAndRole(db, c.Params("id"), c.Params("role"))
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		if err := db.Model(user).Association("Roles").Delete(role); err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not remove role", fiber.StatusInternalServerError))
+		}
+
+		// Drop the user's access tokens so the removed role stops working immediately
+		if err := utils.DeleteUserTokens(user.ID); err != nil {
+			log.Printf("Could not remove tokens of user %d: %v", user.ID, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Role removed successfully",
+		})
+	}
+}
+
+// findUserAndRole loads the user by ID and the role by name
+func findUserAndRole(db *gorm.DB, id string, roleName string) (*model.User, *model.Role, *custom.HttpError) {
+	userID, err := custom.ParseID(id)
+	if err != nil {
+		return nil, nil, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest)
+	}
+
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, nil, custom.NewHttpError("User not found", fiber.StatusNotFound)
+	}
+
+	var role model.Role
+	if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return nil, nil, custom.NewHttpError("Role not found", fiber.StatusNotFound)
+	}
+
+	return &user, &role, nil
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"backend/custom"
+	"backend/model"
+	"backend/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// securityEventLimit caps how many security events are returned at once
+const securityEventLimit = 100
+
+// recordSecurityEvent adds an event made by the request's client to the user's security timeline
+func recordSecurityEvent(c fiber.Ctx, db *gorm.DB, userID uint, email string, eventType string, detail string) {
+	device := requestDevice(c)
+	utils.RecordSecurityEvent(db, model.SecurityEvent{
+		UserID:    userID,
+		Email:     email,
+		Type:      eventType,
+		Detail:    detail,
+		IP:        device.IP,
+		UserAgent: device.UserAgent,
+	})
+}
+
+// GetSecurityEvents lists the current user's logins, failed attempts and account changes
+func GetSecurityEvents(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		limit := securityEventLimit
+		if value := c.Query("limit"); value != "" {
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				return custom.SendErrorResponse(c, custom.NewHttpError("Invalid limit", fiber.StatusBadRequest))
+			}
+			limit = min(limit, securityEventLimit)
+		}
+
+		events, err := utils.ListSecurityEvents(db, userID, limit)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not retrieve security events", fiber.StatusInternalServerError))
+		}
+
+		return c.JSON(events)
+	}
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"backend/custom"
+	"backend/utils"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// requestDevice describes the client making the request
+func requestDevice(c fiber.Ctx) utils.Device {
+	return utils.Device{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+	}
+}
+
+// currentFamily returns the refresh token family of the caller's access token, or the family of
+// its cookie session
+func currentFamily(c fiber.Ctx) string {
+	jwtToken, err := custom.ExtractToken(c)
+	if err != nil {
+		return utils.WebSessionFamily(c)
+	}
+	info, err := utils.LookupToken(jwtToken)
+	if err != nil {
+		return ""
+	}
+	return info.Family
+}
+
+// GetSessions lists the current user's active sessions
+func GetSessions(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		sessions, err := utils.ListSessions(db, userID)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not retrieve sessions", fiber.StatusInternalServerError))
+		}
+
+		// Flag the session the request was made from
+		family := currentFamily(c)
+		for i := range sessions {
+			sessions[i].Current = family != "" && sessions[i].Family == family
+		}
+
+		return c.JSON(sessions)
+	}
+}
+
+// RevokeSession logs out one of the current user's sessions
+func RevokeSession(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		sessionID, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest))
+		}
+
+		if err := utils.RevokeSession(db, userID, uint(sessionID)); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return custom.SendErrorResponse(c, custom.NewHttpError("Session not found", fiber.StatusNotFound))
+			}
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not revoke session", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Session revoked successfully",
+		})
+	}
+}
+
+// RevokeOtherSessions logs out every session of the current user except the caller's
+func RevokeOtherSessions(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		revoked, err := utils.RevokeOtherSessions(db, userID, currentFamily(c))
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not revoke sessions", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Other sessions revoked successfully",
+			"revoked": revoked,
+		})
+	}
+}
+
+// ForceLogout ends every session of a user
+func ForceLogout(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest))
+		}
+
+		if err := utils.RevokeUserTokens(db, uint(userID)); err != nil {
+			log.Printf("Could not force logout of user %d: %v", userID, err)
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not log user out", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "User logged out of all sessions",
+		})
+	}
+}
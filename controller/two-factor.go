@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"backend/config"
+	"backend/custom"
+	"backend/model"
+	"backend/utils"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// EnrollTOTP generates a new TOTP secret for the current user, pending confirmation
+func EnrollTOTP(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("User not found", fiber.StatusNotFound))
+		}
+
+		enabled, err := utils.TwoFactorEnabled(db, userID)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not load two-factor settings", fiber.StatusInternalServerError))
+		}
+		if enabled {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Two-factor authentication is already enabled", fiber.StatusConflict))
+		}
+
+		// Replace any unconfirmed enrollment with a fresh secret
+		twoFactor := model.TwoFactor{UserID: userID, Secret: utils.NewTOTPSecret()}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ?", userID).Delete(&model.TwoFactor{}).Error; err != nil {
+				return err
+			}
+			return tx.Create(&twoFactor).Error
+		})
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not start two-factor enrollment", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":          "Scan the provisioning URI with your authenticator app and confirm with a code",
+			"secret":           twoFactor.Secret,
+			"provisioning_uri": utils.TOTPProvisioningURI(cfg.Auth.TOTPIssuer, user.Email, twoFactor.Secret),
+		})
+	}
+}
+
+// ConfirmTOTP enables two-factor authentication once the first code checks out
+func ConfirmTOTP(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		var request model.TOTPCodeRequest
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		var twoFactor model.TwoFactor
+		if err := db.Where("user_id = ? AND enabled = ?", userID, false).First(&twoFactor).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("No pending two-factor enrollment", fiber.StatusNotFound))
+		}
+
+		var step int64
+		httpErr := verifyCode(c, db, userID, func() (bool, error) {
+			var ok bool
+			step, ok = utils.ValidateTOTP(twoFactor.Secret, request.Code, time.Now())
+			return ok, nil
+		})
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		now := time.Now()
+		if err := db.Model(&twoFactor).Updates(map[string]interface{}{
+			"enabled":        true,
+			"enabled_at":     now,
+			"last_used_step": step,
+		}).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not enable two-factor authentication", fiber.StatusInternalServerError))
+		}
+
+		codes, err := utils.GenerateRecoveryCodes(db, userID)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not generate recovery codes", fiber.StatusInternalServerError))
+		}
+		recordSecurityEvent(c, db, userID, "", model.SecurityTwoFactorEnable, "")
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":        "Two-factor authentication enabled, store the recovery codes somewhere safe",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DisableTOTP turns two-factor authentication off after checking the password and a code
+func DisableTOTP(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		var request model.TOTPDisableRequest
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("User not found", fiber.StatusNotFound))
+		}
+
+		// A stolen session alone must not be enough to turn the second factor off
+		if httpErr := verifyCurrentPassword(c, db, &user, request.CurrentPassword); httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		twoFactor, httpErr := verifyCurrentUserCode(c, db, userID, request.Code)
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ?", twoFactor.UserID).Delete(&model.RecoveryCode{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(twoFactor).Error
+		})
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not disable two-factor authentication", fiber.StatusInternalServerError))
+		}
+		recordSecurityEvent(c, db, twoFactor.UserID, "", model.SecurityTwoFactorDisable, "")
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Two-factor authentication disabled",
+		})
+	}
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a code
+func RegenerateRecoveryCodes(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		userID, err := custom.CurrentUserID(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		var request model.TOTPCodeRequest
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		twoFactor, httpErr := verifyCurrentUserCode(c, db, userID, request.Code)
+		if httpErr != nil {
+			return custom.SendErrorResponse(c, httpErr)
+		}
+
+		codes, err := utils.GenerateRecoveryCodes(db, twoFactor.UserID)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not generate recovery codes", fiber.StatusInternalServerError))
+		}
+		recordSecurityEvent(c, db, twoFactor.UserID, "", model.SecurityRecoveryCodesRenew, "")
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":        "Recovery codes regenerated, the previous codes no longer work",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// LoginTwoFactor completes a login by exchanging the interim token and a code for tokens
+func LoginTwoFactor(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request model.TwoFactorLoginRequest
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		invalidToken := custom.NewHttpError("Invalid or expired interim token", fiber.StatusUnauthorized)
+
+		userID, jti, err := utils.ParseInterimToken(request.InterimToken)
+		if err != nil {
+			return custom.SendErrorResponse(c, invalidToken)
+		}
+
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return custom.SendErrorResponse(c, invalidToken)
+		}
+
+		var twoFactor model.TwoFactor
+		if err := db.Where("user_id = ? AND enabled = ?", userID, true).First(&twoFactor).Error; err != nil {
+			return custom.SendErrorResponse(c, invalidToken)
+		}
+
+		// Wrong codes count as failed logins of the account
+		wait, err := utils.CheckLoginThrottle(db, user.Email, c.IP())
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not check login attempts", fiber.StatusInternalServerError))
+		}
+		if wait > 0 {
+			return tooManyLoginAttempts(c, wait)
+		}
+
+		ok, err := utils.VerifyTwoFactorCode(db, &twoFactor, request.Code)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not verify two-factor code", fiber.StatusInternalServerError))
+		}
+		if !ok {
+			locked, err := utils.RecordLoginFailure(db, user.Email, c.IP())
+			if err != nil {
+				log.Printf("Could not record failed login: %v", err)
+			}
+			recordSecurityEvent(c, db, user.ID, user.Email, model.SecurityLoginFailure, "Invalid two-factor code")
+			if locked {
+				recordSecurityEvent(c, db, user.ID, user.Email, model.SecurityLoginLockout, "")
+				return tooManyLoginAttempts(c, 0)
+			}
+			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid two-factor code", fiber.StatusBadRequest))
+		}
+
+		// The interim token is single use
+		if err := utils.ConsumeInterimToken(jti); err != nil {
+			return custom.SendErrorResponse(c, invalidToken)
+		}
+
+		if err := utils.ResetLoginFailures(db, user.Email); err != nil {
+			log.Printf("Could not reset failed logins: %v", err)
+		}
+
+		return issueLogin(c, db, user.ID, true)
+	}
+}
+
+// verifyCurrentUserCode checks a TOTP or recovery code of the user's enabled two-factor settings
+func verifyCurrentUserCode(c fiber.Ctx, db *gorm.DB, userID uint, code string) (*model.TwoFactor, *custom.HttpError) {
+	var twoFactor model.TwoFactor
+	if err := db.Where("user_id = ? AND enabled = ?", userID, true).First(&twoFactor).Error; err != nil {
+		return nil, custom.NewHttpError("Two-factor authentication is not enabled", fiber.StatusBadRequest)
+	}
+
+	httpErr := verifyCode(c, db, userID, func() (bool, error) {
+		return utils.VerifyTwoFactorCode(db, &twoFactor, code)
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return &twoFactor, nil
+}
+
+// verifyCode runs a two-factor code check of a signed-in user, throttling wrong codes the way
+// verifyCurrentPassword throttles wrong passwords
+func verifyCode(c fiber.Ctx, db *gorm.DB, userID uint, check func() (bool, error)) *custom.HttpError {
+	wait, err := utils.CheckTwoFactorConfirmThrottle(db, userID)
+	if err != nil {
+		return custom.NewHttpError("Could not check two-factor attempts", fiber.StatusInternalServerError)
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return custom.NewHttpError("Too many two-factor attempts, please try again later", fiber.StatusTooManyRequests)
+	}
+
+	ok, err := check()
+	if err != nil {
+		return custom.NewHttpError("Could not verify two-factor code", fiber.StatusInternalServerError)
+	}
+	if !ok {
+		locked, err := utils.RecordTwoFactorConfirmFailure(db, userID)
+		if err != nil {
+			log.Printf("Could not record failed two-factor check: %v", err)
+		}
+		if locked {
+			return custom.NewHttpError("Too many two-factor attempts, please try again later", fiber.StatusTooManyRequests)
+		}
+		return custom.NewHttpError("Invalid two-factor code", fiber.StatusBadRequest)
+	}
+
+	if err := utils.ResetTwoFactorConfirmFailures(db, userID); err != nil {
+		log.Printf("Could not reset failed two-factor checks: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package controller_test
+
+import (
+	"backend/model"
+	"backend/utils"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+func TestTwoFactorCodeThrottle(t *testing.T) {
+	codeBody := func(code string) string { return fmt.Sprintf(`{"code":%q}`, code) }
+	tests := []struct {
+		name    string
+		path    string
+		enabled bool // Whether two-factor authentication is already enabled
+		body    func(code string) string
+	}{
+		{name: "confirm enrollment", path: "/api/person/2fa/confirm", body: codeBody},
+		{name: "regenerate recovery codes", path: "/api/person/2fa/recovery-codes", enabled: true, body: codeBody},
+		{
+			name:    "disable with the right password",
+			path:    "/api/person/2fa/disable",
+			enabled: true,
+			body: func(code string) string {
+				return fmt.Sprintf(`{"current_password":"password1","code":%q}`, code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			setNoDelayThrottle(t, 3)
+			user := newTestUser(t, db, "user@example.com", "password1")
+			token, _ := loginTokens(t, app, "user@example.com", "password1")
+			recoveryCodes := newTestTwoFactor(t, db, user, tt.enabled)
+
+			for attempt := 1; attempt <= 3; attempt++ {
+				want := fiber.StatusBadRequest
+				if attempt == 3 {
+					want = fiber.StatusTooManyRequests
+				}
+				if resp, body := send(t, app, fiber.MethodPost, tt.path, tt.body("wrong-code"), bearer(token)); resp.StatusCode != want {
+					t.Fatalf("attempt %d answered with %d, want %d: %v", attempt, resp.StatusCode, want, body)
+				}
+			}
+
+			// While locked even a right code is refused
+			code := "wrong-code"
+			if len(recoveryCodes) > 0 {
+				code = recoveryCodes[0]
+			}
+			resp, body := send(t, app, fiber.MethodPost, tt.path, tt.body(code), bearer(token))
+			if resp.StatusCode != fiber.StatusTooManyRequests || resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+				t.Fatalf("locked check answered with %d and Retry-After %q: %v", resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter), body)
+			}
+		})
+	}
+}
+
+func TestDisableTOTP(t *testing.T) {
+	tests := []struct {
+		name        string
+		password    string
+		wantStatus  int
+		wantEnabled bool
+	}{
+		{name: "right password and code", password: "password1", wantStatus: fiber.StatusOK},
+		{name: "wrong password", password: "password2", wantStatus: fiber.StatusBadRequest, wantEnabled: true},
+		{name: "missing password", wantStatus: fiber.StatusBadRequest, wantEnabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			user := newTestUser(t, db, "user@example.com", "password1")
+			token, _ := loginTokens(t, app, "user@example.com", "password1")
+			recoveryCodes := newTestTwoFactor(t, db, user, true)
+
+			body := fmt.Sprintf(`{"current_password":%q,"code":%q}`, tt.password, recoveryCodes[0])
+			if resp, body := send(t, app, fiber.MethodPost, "/api/person/2fa/disable", body, bearer(token)); resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %v", resp.StatusCode, tt.wantStatus, body)
+			}
+
+			enabled, err := utils.TwoFactorEnabled(db, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if enabled != tt.wantEnabled {
+				t.Fatalf("two-factor enabled is %v, want %v", enabled, tt.wantEnabled)
+			}
+		})
+	}
+}
+
+// newTestTwoFactor stores two-factor settings for the user and, once enabled, returns their
+// recovery codes
+func newTestTwoFactor(t *testing.T, db *gorm.DB, user *model.User, enabled bool) []string {
+	t.Helper()
+	if err := db.Create(&model.TwoFactor{UserID: user.ID, Secret: utils.NewTOTPSecret(), Enabled: enabled}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !enabled {
+		return nil
+	}
+	codes, err := utils.GenerateRecoveryCodes(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codes
+}
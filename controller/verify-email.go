@@ -1,7 +1,15 @@
 package controller
 
 import (
+	"backend/config"
+	"backend/custom"
 	"backend/model"
+	"backend/utils"
+	"log"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 	"gorm.io/gorm"
@@ -11,19 +19,19 @@ import (
 func VerifyEmail(db *gorm.DB) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		token := c.Query("token")
-		var user model.User
-
-		// Find the user with the provided verification token
-		if err := db.Where("verification_token = ?", token).First(&user).Error; err != nil {
+		if token == "" {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": "Invalid or expired verification token",
 			})
 		}
 
-		// Update user as verified
-		user.IsVerified = true
-		user.VerificationToken = "" // Optionally, clear the token
-		if err := db.Save(&user).Error; err != nil {
+		// Consume the token and mark the user as verified
+		if _, err := utils.ConsumeEmailVerification(db, token); err != nil {
+			if err == utils.ErrInvalidVerificationToken {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": "Invalid or expired verification token",
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"message": "Could not update user verification status",
 			})
@@ -35,3 +43,73 @@ func VerifyEmail(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// ResendVerification emails a new verification link to an unverified account
+func ResendVerification(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request model.ResendVerificationRequest
+
+		if err := c.Bind().Body(&request); err != nil {
+			log.Printf("Validation errors: %+v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
+		}
+
+		// Same response whether or not the email exists or is already verified
+		response := fiber.Map{
+			"message": "If the email is registered and not yet verified, a verification link has been sent",
+		}
+
+		var user model.User
+		if err := db.Where("email = ?", request.Email).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(fiber.StatusOK).JSON(response)
+			}
+			err := custom.NewHttpError("Could not find user", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+		if user.IsVerified {
+			return c.Status(fiber.StatusOK).JSON(response)
+		}
+
+		wait, err := utils.VerificationResendWait(db, user.ID)
+		if err != nil {
+			err := custom.NewHttpError("Could not check verification emails", fiber.StatusInternalServerError)
+			return custom.SendErrorResponse(c, err)
+		}
+		if wait > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			err := custom.NewHttpError("A verification email was sent recently, please try again later", fiber.StatusTooManyRequests)
+			return custom.SendErrorResponse(c, err)
+		}
+
+		if err := sendVerificationEmail(db, cfg, &user); err != nil {
+			log.Printf("Could not send verification email: %v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not send verification email", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// sendVerificationEmail emails the user a new expiring verification link
+func sendVerificationEmail(db *gorm.DB, cfg *config.Config, user *model.User) error {
+	verificationToken, err := utils.CreateEmailVerification(db, user.ID)
+	if err != nil {
+		return err
+	}
+	return mailVerificationLink(cfg, user, verificationToken)
+}
+
+// mailVerificationLink emails the user the link for an already stored verification token
+func mailVerificationLink(cfg *config.Config, user *model.User, verificationToken string) error {
+	// Construct the verification link
+	verificationLink := publicLink(cfg, "/api/person/verify", verificationToken)
+
+	// Send the verification email
+	emailBody := "Please verify your email by clicking the following link: " + verificationLink
+	return utils.GoogleSendEmail(user.Email, "Email Verification", emailBody, verificationLink)
+}
+
+// publicLink builds an emailed link to path on the public URL, carrying the token
+func publicLink(cfg *config.Config, path string, token string) string {
+	return strings.TrimSuffix(cfg.Server.PublicURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
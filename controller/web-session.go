@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"backend/custom"
+	"backend/utils"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// GetWebSession returns the caller and the CSRF token of its cookie session, for pages that
+// need the token to submit forms
+func GetWebSession() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		principal, err := custom.CurrentPrincipal(c)
+		if err != nil {
+			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusUnauthorized))
+		}
+
+		csrfToken, ok := c.Locals("csrf_token").(string)
+		if !ok {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Not logged in with a session", fiber.StatusBadRequest))
+		}
+
+		return c.JSON(fiber.Map{
+			"principal":  principal,
+			"csrf_token": csrfToken,
+		})
+	}
+}
+
+// LogoutWebSession ends the caller's cookie session
+func LogoutWebSession(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if _, ok := c.Locals("csrf_token").(string); !ok {
+			return custom.SendErrorResponse(c, custom.NewHttpError("Not logged in with a session", fiber.StatusBadRequest))
+		}
+
+		if err := utils.EndWebSession(c, db); err != nil {
+			log.Printf("Could not end session: %v", err)
+			return custom.SendErrorResponse(c, custom.NewHttpError("Could not log out", fiber.StatusInternalServerError))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logout successful"})
+	}
+}
@@ -0,0 +1,29 @@
+package custom
+
+import (
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// CurrentPrincipal returns the principal stored by AuthMiddleware
+func CurrentPrincipal(c fiber.Ctx) (*utils.Principal, error) {
+	principal, ok := c.Locals("principal").(*utils.Principal)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "No token provided")
+	}
+	return principal, nil
+}
+
+// CurrentUserID returns the ID of the user signed in with a bearer token.
+// API keys cannot act as the user for self-service endpoints.
+func CurrentUserID(c fiber.Ctx) (uint, error) {
+	principal, err := CurrentPrincipal(c)
+	if err != nil {
+		return 0, err
+	}
+	if principal.Type != utils.PrincipalUser {
+		return 0, fiber.NewError(fiber.StatusForbidden, "This action requires a user token")
+	}
+	return principal.UserID, nil
+}
@@ -18,6 +18,12 @@ func NewHttpError(message string, code int) *HttpError {
 	}
 }
 
+// Error returns the message, so an HttpError can be returned through functions such as a
+// transaction callback
+func (e *HttpError) Error() string {
+	return e.Message
+}
+
 // SendErrorResponse sends a JSON error response.
 func SendErrorResponse(c fiber.Ctx, err *HttpError) error {
 	return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
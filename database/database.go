@@ -2,30 +2,78 @@
 package database
 
 import (
+	"backend/config"
 	"fmt"
 	"log"
+	"time"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// InitDB initializes the database connection
-func InitDB() *gorm.DB {
-	// Define database connection parameters
-	const (
-		host     = "localhost" // Change to your local IP address
-		port     = 5432
-		user     = "postgres"
-		password = "light1114"
-		dbname   = "backend"
-	)
-
-	// Initialize database connection
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// InitDB initializes the database connection with the configured driver, exiting when the
+// database is still unreachable after every connection attempt
+func InitDB(cfg config.DatabaseConfig) *gorm.DB {
+	db, err := Connect(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-
 	return db
 }
+
+// Connect opens the database and configures the pool, retrying with exponential backoff
+// so the server can start alongside a database that is still booting
+func Connect(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	delay := cfg.Connect.Backoff
+	for attempt := 1; ; attempt++ {
+		db, err := open(cfg)
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= cfg.Connect.Attempts {
+			return nil, fmt.Errorf("failed to connect to the database after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("database connection attempt %d/%d failed, retrying in %s: %v", attempt, cfg.Connect.Attempts, delay, err)
+		time.Sleep(delay)
+		delay = min(delay*2, cfg.Connect.MaxBackoff)
+	}
+}
+
+// open makes a single connection attempt, gorm pings the database before returning
+func open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := Dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		// Release the pool opened before the failed ping
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+		}
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+
+	// SQLite allows a single writer, one connection avoids "database is locked" errors.
+	// It is kept idle and never recycled, closing it would drop an in-memory database.
+	if cfg.Driver == DriverSQLite {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"backend/config"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	gormpostgres "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+)
+
+// Dialector returns the gorm dialector of the configured driver
+func Dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case DriverPostgres, "":
+		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode)
+		return gormpostgres.Open(dsn), nil
+	case DriverMySQL:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true&loc=UTC",
+			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+		return mysql.Open(dsn), nil
+	case DriverSQLite:
+		// Foreign keys are off by default in SQLite, the busy timeout waits for other writers
+		separator := "?"
+		if strings.Contains(cfg.Name, "?") {
+			separator = "&"
+		}
+		return sqlite.Open(cfg.Name + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+// jsonKey matches the JSON keys that can be used in a path
+var jsonKey = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// JSONText returns the SQL expression reading the text value at path in a JSON column,
+// for example JSONText(db, "branch_data", "location", "address"). The keys are written into
+// the SQL, so only letters, digits and underscores are allowed.
+func JSONText(db *gorm.DB, column string, path ...string) string {
+	for _, key := range path {
+		if !jsonKey.MatchString(key) {
+			panic(fmt.Sprintf("invalid JSON key %q", key))
+		}
+	}
+
+	switch db.Dialector.Name() {
+	case DriverMySQL:
+		return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '$.%s'))", column, strings.Join(path, "."))
+	case DriverSQLite:
+		return fmt.Sprintf("json_extract(%s, '$.%s')", column, strings.Join(path, "."))
+	default:
+		return fmt.Sprintf("%s #>> '{%s}'", column, strings.Join(path, ","))
+	}
+}
@@ -0,0 +1,84 @@
+package database_test
+
+import (
+	"backend/config"
+	"backend/database"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestJSONText(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialector gorm.Dialector
+		path      []string
+		want      string
+	}{
+		{name: "postgres key", dialector: postgres.Open(""), path: []string{"branch_code"}, want: "branch_data #>> '{branch_code}'"},
+		{name: "postgres path", dialector: postgres.Open(""), path: []string{"location", "address"}, want: "branch_data #>> '{location,address}'"},
+		{name: "mysql key", dialector: mysql.Open(""), path: []string{"branch_code"}, want: "JSON_UNQUOTE(JSON_EXTRACT(branch_data, '$.branch_code'))"},
+		{name: "mysql path", dialector: mysql.Open(""), path: []string{"location", "address"}, want: "JSON_UNQUOTE(JSON_EXTRACT(branch_data, '$.location.address'))"},
+		{name: "sqlite key", dialector: sqlite.Open(""), path: []string{"branch_code"}, want: "json_extract(branch_data, '$.branch_code')"},
+		{name: "sqlite path", dialector: sqlite.Open(""), path: []string{"location", "address"}, want: "json_extract(branch_data, '$.location.address')"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &gorm.DB{Config: &gorm.Config{Dialector: tt.dialector}}
+			if got := database.JSONText(db, "branch_data", tt.path...); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONTextRejectsUnsafeKeys(t *testing.T) {
+	for _, key := range []string{"", "code'", "a.b", "a b", "a}"} {
+		t.Run(key, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("key %q accepted", key)
+				}
+			}()
+			database.JSONText(&gorm.DB{Config: &gorm.Config{Dialector: postgres.Open("")}}, "branch_data", key)
+		})
+	}
+}
+
+func TestJSONTextSQLite(t *testing.T) {
+	db, err := database.Connect(config.DatabaseConfig{
+		Driver:  database.DriverSQLite,
+		Name:    "file:TestJSONTextSQLite?mode=memory&cache=shared",
+		Connect: config.ConnectConfig{Attempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.Exec("CREATE TABLE documents (data TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO documents (data) VALUES ('{"code":"BR-1","location":{"address":"1 Market Street"}}')`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var code, address string
+	if err := db.Table("documents").Select(database.JSONText(db, "data", "code")).Scan(&code).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Table("documents").Select(database.JSONText(db, "data", "location", "address")).Scan(&address).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code != "BR-1" || address != "1 Market Street" {
+		t.Fatalf("got %q and %q", code, address)
+	}
+}
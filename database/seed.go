@@ -0,0 +1,84 @@
+package database
+
+import (
+	"backend/model"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// rolePermissions lists the permissions granted to each built-in role
+var rolePermissions = map[string][]string{
+	model.RoleAdmin: {
+		model.PermissionPersonCreate,
+		model.PermissionPersonRead,
+		model.PermissionPersonUpdate,
+		model.PermissionPersonDelete,
+		model.PermissionPersonExport,
+		model.PermissionBranchRead,
+		model.PermissionBranchCreate,
+		model.PermissionBranchDelete,
+		model.PermissionRoleManage,
+		model.PermissionSessionManage,
+		model.PermissionAPIKeyManage,
+		model.PermissionImpersonate,
+		model.PermissionAuditRead,
+		model.PermissionTokenIntrospect,
+		model.PermissionTokenRevoke,
+	},
+	model.RoleUser: {
+		model.PermissionBranchRead,
+	},
+	model.RoleTokenClient: {
+		model.PermissionTokenIntrospect,
+		model.PermissionTokenRevoke,
+	},
+}
+
+// SeedRoles creates the built-in roles and permissions and grants the admin role
+// to the user with adminEmail, if one is given, exists and has verified the address
+func SeedRoles(db *gorm.DB, adminEmail string) error {
+	for roleName, permissionNames := range rolePermissions {
+		var role model.Role
+		if err := db.Where(model.Role{Name: roleName}).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+
+		permissions := make([]model.Permission, 0, len(permissionNames))
+		for _, name := range permissionNames {
+			var permission model.Permission
+			if err := db.Where(model.Permission{Name: name}).FirstOrCreate(&permission).Error; err != nil {
+				return err
+			}
+			permissions = append(permissions, permission)
+		}
+
+		if err := db.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+			return err
+		}
+	}
+
+	if adminEmail == "" {
+		return nil
+	}
+
+	var admin model.User
+	if err := db.Where("email = ?", adminEmail).First(&admin).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	// Whoever registers the address first owns the row, only a confirmed owner gets admin
+	if !admin.IsVerified {
+		log.Printf("Admin role not granted, the ADMIN_EMAIL account is not verified yet")
+		return nil
+	}
+
+	var adminRole model.Role
+	if err := db.Where("name = ?", model.RoleAdmin).First(&adminRole).Error; err != nil {
+		return err
+	}
+	return db.Model(&admin).Association("Roles").Append(&adminRole)
+}
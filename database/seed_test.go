@@ -0,0 +1,75 @@
+package database_test
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/migrations"
+	"backend/model"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB opens a migrated in-memory SQLite database private to the test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := database.Connect(config.DatabaseConfig{
+		Driver:  database.DriverSQLite,
+		Name:    fmt.Sprintf("file:%s?mode=memory&cache=shared", name),
+		Connect: config.ConnectConfig{Attempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSeedRolesAdmin(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		verified  bool
+		wantAdmin bool
+	}{
+		{name: "verified account", email: "admin@example.com", verified: true, wantAdmin: true},
+		{name: "unverified account", email: "admin@example.com", verified: false, wantAdmin: false},
+		{name: "other account", email: "someone@example.com", verified: true, wantAdmin: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user := &model.User{Name: "adminuser", Age: 30, Email: tt.email, Password: "unused", IsVerified: tt.verified}
+			if err := db.Create(user).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if err := database.SeedRoles(db, "admin@example.com"); err != nil {
+				t.Fatal(err)
+			}
+
+			var count int64
+			if err := db.Table("user_roles").
+				Joins("JOIN roles ON roles.id = user_roles.role_id").
+				Where("user_roles.user_id = ? AND roles.name = ?", user.ID, model.RoleAdmin).
+				Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got := count == 1; got != tt.wantAdmin {
+				t.Fatalf("admin granted %v, want %v", got, tt.wantAdmin)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Storage is a fiber.Storage kept in a database table, so it works with every supported driver.
+// Its k, v and e columns match the tables created by the gofiber storage drivers.
+type Storage struct {
+	db    *gorm.DB
+	table string
+}
+
+// storageEntry is a row of a storage table
+type storageEntry struct {
+	Key       string `gorm:"column:k;primaryKey;size:64"`
+	Value     []byte `gorm:"column:v;not null"`
+	ExpiresAt int64  `gorm:"column:e;not null;default:0"` // Unix seconds, zero never expires
+}
+
+// NewStorage creates the table if needed and returns the storage kept in it
+func NewStorage(db *gorm.DB, table string) (*Storage, error) {
+	if err := db.Table(table).AutoMigrate(&storageEntry{}); err != nil {
+		return nil, err
+	}
+	return &Storage{db: db, table: table}, nil
+}
+
+// Get returns the value of key, nil if it is absent or expired
+func (s *Storage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	// Find rather than Take, a missing key is common and not worth logging
+	var entry storageEntry
+	result := s.db.Table(s.table).Where("k = ?", key).Limit(1).Find(&entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	if entry.ExpiresAt != 0 && entry.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	return entry.Value, nil
+}
+
+// Set stores value under key, a zero exp never expires
+func (s *Storage) Set(key string, value []byte, exp time.Duration) error {
+	if key == "" || len(value) == 0 {
+		return nil
+	}
+
+	entry := storageEntry{Key: key, Value: value}
+	if exp != 0 {
+		entry.ExpiresAt = time.Now().Add(exp).Unix()
+	}
+	return s.db.Table(s.table).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "k"}},
+		DoUpdates: clause.AssignmentColumns([]string{"v", "e"}),
+	}).Create(&entry).Error
+}
+
+// Delete removes key
+func (s *Storage) Delete(key string) error {
+	_, err := s.Remove(key)
+	return err
+}
+
+// Remove removes key and reports whether it was present
+func (s *Storage) Remove(key string) (bool, error) {
+	result := s.db.Table(s.table).Where("k = ?", key).Delete(&storageEntry{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// PurgeExpired removes every entry that expired before now
+func (s *Storage) PurgeExpired(now time.Time) (int, error) {
+	result := s.db.Table(s.table).Where("e <= ? AND e != 0", now.Unix()).Delete(&storageEntry{})
+	return int(result.RowsAffected), result.Error
+}
+
+// Reset removes every entry
+func (s *Storage) Reset() error {
+	return s.db.Table(s.table).Where("1 = 1").Delete(&storageEntry{}).Error
+}
+
+// Close does nothing, the database connection is shared with the application
+func (s *Storage) Close() error {
+	return nil
+}
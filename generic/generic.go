@@ -2,6 +2,7 @@ package generic
 
 import (
 	"backend/custom"
+	"errors"
 	"log"
 	"reflect"
 	"strconv"
@@ -9,45 +10,79 @@ import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/xuri/excelize/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// CreateResource creates a resource and can optionally preload related models
+// CreateResource creates a resource and can optionally create related models with it. The
+// resource and its related models are written in one transaction, so a failure creates nothing.
+// input and relatedModels are templates, every request works on its own copy.
 func CreateResource[T any](db *gorm.DB, input *T, relatedModels ...interface{}) fiber.Handler {
+	return CreateResourceWithHook(db, input, nil, relatedModels...)
+}
+
+// CreateHook prepares a bound resource before it is created, for example hashing a secret.
+// Returning an error rejects the request with it.
+type CreateHook[T any] func(resource *T) *custom.HttpError
+
+// CreateResourceWithHook is CreateResource with a hook run on every resource before it is created
+func CreateResourceWithHook[T any](db *gorm.DB, input *T, hook CreateHook[T], relatedModels ...interface{}) fiber.Handler {
 	return func(c fiber.Ctx) error {
-		// Bind the request body to the main input model
-		if err := c.Bind().Body(input); err != nil {
+		// Bind the request body to a copy of the main input model
+		resource := new(T)
+		*resource = *input
+		if err := c.Bind().Body(resource); err != nil {
 			log.Printf("Error parsing body: %+v", err)
 			return custom.SendErrorResponse(c, custom.NewHttpError(err.Error(), fiber.StatusBadRequest))
 		}
-
-		// Create the main resource
-		if err := db.Create(input).Error; err != nil {
-			return custom.SendErrorResponse(c, custom.NewHttpError("Could not create resource", fiber.StatusInternalServerError))
+		if hook != nil {
+			if httpErr := hook(resource); httpErr != nil {
+				return custom.SendErrorResponse(c, httpErr)
+			}
 		}
 
-		// Extract the UserID from the input using reflect
-		val := reflect.ValueOf(input).Elem() // Dereference the pointer to get the value
-		idField := val.FieldByName("ID")
-		if !idField.IsValid() {
-			return custom.SendErrorResponse(c, custom.NewHttpError("ID field not found in resource", fiber.StatusInternalServerError))
-		}
-		userID := idField.Interface().(uint) // Assuming ID is of type uint
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Create the main resource
+			if err := tx.Create(resource).Error; err != nil {
+				return custom.NewHttpError("Could not create resource", fiber.StatusInternalServerError)
+			}
 
-		// Iterate over related models and create them if they are not nil
-		for _, relatedModel := range relatedModels {
-			if relatedModel != nil {
-				// Use reflection to set the UserID for related models
-				relatedVal := reflect.ValueOf(relatedModel).Elem() // Dereference the pointer to get the value
-				userIDField := relatedVal.FieldByName("UserID")
-				if userIDField.IsValid() && userIDField.CanSet() {
-					userIDField.SetUint(uint64(userID)) // Set the UserID
-				}
+			// Extract the UserID from the resource using reflect
+			val := reflect.ValueOf(resource).Elem() // Dereference the pointer to get the value
+			idField := val.FieldByName("ID")
+			if !idField.IsValid() {
+				return custom.NewHttpError("ID field not found in resource", fiber.StatusInternalServerError)
+			}
+			userID := idField.Interface().(uint) // Assuming ID is of type uint
+
+			// Iterate over related models and create them if they are not nil
+			for _, relatedModel := range relatedModels {
+				if relatedModel != nil {
+					// Copy the template so IDs from earlier requests are not reused
+					template := reflect.ValueOf(relatedModel).Elem() // Dereference the pointer to get the value
+					related := reflect.New(template.Type())
+					related.Elem().Set(template)
+
+					// Use reflection to set the UserID for related models
+					userIDField := related.Elem().FieldByName("UserID")
+					if userIDField.IsValid() && userIDField.CanSet() {
+						userIDField.SetUint(uint64(userID)) // Set the UserID
+					}
 
-				// Create the related resource
-				if err := db.Create(relatedModel).Error; err != nil {
-					return custom.SendErrorResponse(c, custom.NewHttpError("Could not create related resource", fiber.StatusInternalServerError))
+					// Create the related resource
+					if err := tx.Create(related.Interface()).Error; err != nil {
+						return custom.NewHttpError("Could not create related resource", fiber.StatusInternalServerError)
+					}
 				}
 			}
+			return nil
+		})
+		if err != nil {
+			// The transaction was rolled back, nothing was created
+			var httpErr *custom.HttpError
+			if !errors.As(err, &httpErr) {
+				httpErr = custom.NewHttpError("Could not create resource", fiber.StatusInternalServerError)
+			}
+			return custom.SendErrorResponse(c, httpErr)
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -105,8 +140,12 @@ func GetResourceByID[T any](db *gorm.DB, preloads []string) fiber.Handler {
 	}
 }
 
-// Update a resource by ID
-func UpdateResource[T any](db *gorm.DB, input *T) fiber.Handler {
+// Update a resource by ID, leaving the omitted fields and every association untouched.
+// input is a template, every request works on its own copy.
+func UpdateResource[T any](db *gorm.DB, input *T, omit ...string) fiber.Handler {
+	// Associations such as Roles are never written from a request body
+	omit = append(append([]string{}, omit...), clause.Associations)
+
 	return func(c fiber.Ctx) error {
 		id := c.Params("id")
 		resourceID, err := strconv.ParseUint(id, 10, 64)
@@ -114,8 +153,11 @@ func UpdateResource[T any](db *gorm.DB, input *T) fiber.Handler {
 			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid ID", fiber.StatusBadRequest))
 		}
 
-		// Parse request body into the input model
-		if err := c.Bind().Body(input); err != nil {
+		// Parse request body into a copy of the input model, so fields of earlier requests
+		// are not written again
+		resource := new(T)
+		*resource = *input
+		if err := c.Bind().Body(resource); err != nil {
 			log.Println("Error parsing body:", err)
 			return custom.SendErrorResponse(c, custom.NewHttpError("Invalid request body", fiber.StatusBadRequest))
 		}
@@ -126,8 +168,8 @@ func UpdateResource[T any](db *gorm.DB, input *T) fiber.Handler {
 			return custom.SendErrorResponse(c, custom.NewHttpError("Resource not found", fiber.StatusNotFound))
 		}
 
-		// Update only the fields present in the input struct
-		if err := db.Model(&existingUser).Where("id = ?", resourceID).Updates(input).Error; err != nil {
+		// Update only the fields present in the input struct, except the omitted ones
+		if err := db.Model(&existingUser).Where("id = ?", resourceID).Omit(omit...).Updates(resource).Error; err != nil {
 			return custom.SendErrorResponse(c, custom.NewHttpError("Could not update resource", fiber.StatusInternalServerError))
 		}
 
@@ -0,0 +1,146 @@
+package generic_test
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/generic"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// widget is a resource with one optional field and an association
+type widget struct {
+	ID    uint
+	Name  string
+	Color string
+	Tags  []tag `gorm:"many2many:widget_tags"`
+}
+
+// tag is the association of a widget
+type tag struct {
+	ID   uint
+	Name string
+}
+
+// part is created with a widget, its UserID is set to the widget's ID
+type part struct {
+	ID     uint
+	UserID uint
+	Name   string
+}
+
+// newTestDB opens an in-memory SQLite database private to the test with the given tables
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := database.Connect(config.DatabaseConfig{
+		Driver:  database.DriverSQLite,
+		Name:    fmt.Sprintf("file:%s?mode=memory&cache=shared", name),
+		Connect: config.ConnectConfig{Attempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// request sends a JSON body to the handler and returns the status
+func request(t *testing.T, app *fiber.App, method string, path string, body string) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestCreateResourceWithHookRollsBack(t *testing.T) {
+	tests := []struct {
+		name        string
+		migrate     []interface{}
+		wantStatus  int
+		wantWidgets int64
+	}{
+		{name: "related insert succeeds", migrate: []interface{}{&widget{}, &tag{}, &part{}}, wantStatus: fiber.StatusOK, wantWidgets: 1},
+		// Without a parts table the related insert fails
+		{name: "related insert fails", migrate: []interface{}{&widget{}, &tag{}}, wantStatus: fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, tt.migrate...)
+			app := fiber.New()
+			app.Post("/", generic.CreateResourceWithHook(db, &widget{}, nil, &part{Name: "wheel"}))
+
+			if status := request(t, app, fiber.MethodPost, "/", `{"Name":"cart"}`); status != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", status, tt.wantStatus)
+			}
+
+			var widgets int64
+			if err := db.Model(&widget{}).Count(&widgets).Error; err != nil {
+				t.Fatal(err)
+			}
+			if widgets != tt.wantWidgets {
+				t.Fatalf("got %d widgets, want %d", widgets, tt.wantWidgets)
+			}
+		})
+	}
+}
+
+func TestUpdateResource(t *testing.T) {
+	db := newTestDB(t, &widget{}, &tag{})
+	first := widget{Name: "first", Color: "red"}
+	second := widget{Name: "second", Color: "red"}
+	for _, w := range []*widget{&first, &second} {
+		if err := db.Create(w).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	app.Put("/:id", generic.UpdateResource(db, &widget{}, "Name"))
+
+	if status := request(t, app, fiber.MethodPut, fmt.Sprintf("/%d", first.ID), `{"Color":"blue","Name":"renamed","Tags":[{"Name":"admin"}]}`); status != fiber.StatusOK {
+		t.Fatalf("first update answered with %d", status)
+	}
+	// The second request sends no color, the first request's must not be written again
+	if status := request(t, app, fiber.MethodPut, fmt.Sprintf("/%d", second.ID), `{}`); status != fiber.StatusOK {
+		t.Fatalf("second update answered with %d", status)
+	}
+
+	var got []widget
+	if err := db.Preload("Tags").Order("id").Find(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Name != "first" || got[0].Color != "blue" {
+		t.Fatalf("first widget is %+v, want its name kept and the color changed", got[0])
+	}
+	if got[1].Color != "red" {
+		t.Fatalf("second widget got color %q from the earlier request", got[1].Color)
+	}
+
+	// Associations in the body are ignored
+	var tags int64
+	if err := db.Model(&tag{}).Count(&tags).Error; err != nil {
+		t.Fatal(err)
+	}
+	if tags != 0 || len(got[0].Tags) != 0 {
+		t.Fatalf("got %d tags and %v on the widget, want none", tags, got[0].Tags)
+	}
+}
@@ -0,0 +1,132 @@
+// Package lifecycle starts the server and its background components, and stops them in order
+// when the process receives SIGINT or SIGTERM
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Hook is a component of the application. Hooks are started in the order they are
+// registered and stopped in reverse order, so a component is stopped before the ones it
+// depends on.
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error // Optional, must return once the component is running
+	Stop  func(ctx context.Context) error // Optional, ctx expires at the shutdown deadline
+}
+
+// Manager runs the registered hooks until the process is asked to stop
+type Manager struct {
+	timeout time.Duration
+	hooks   []Hook
+	failed  chan error
+}
+
+// New creates a manager that gives its hooks timeout to stop once shutdown begins
+func New(timeout time.Duration) *Manager {
+	return &Manager{timeout: timeout, failed: make(chan error, 1)}
+}
+
+// Append registers a hook, it is started after and stopped before every hook registered earlier
+func (m *Manager) Append(hook Hook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// Go registers a background worker. run is started in its own goroutine and must return
+// once ctx is cancelled, shutdown waits for it until the deadline.
+func (m *Manager) Go(name string, run func(ctx context.Context)) {
+	var cancel context.CancelFunc
+	done := make(chan struct{})
+
+	m.Append(Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				defer close(done)
+				run(ctx)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// Fail shuts the application down because a component stopped unexpectedly, such as the
+// server failing to accept connections. Run returns err.
+func (m *Manager) Fail(err error) {
+	select {
+	case m.failed <- err:
+	default: // Already shutting down
+	}
+}
+
+// Run starts every hook and blocks until SIGINT, SIGTERM or Fail, then stops the started hooks.
+// A second signal during shutdown exits immediately.
+func (m *Manager) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started, err := m.start(ctx)
+	if err == nil {
+		select {
+		case <-ctx.Done():
+			log.Printf("Shutting down, waiting up to %s for requests and workers to finish", m.timeout)
+		case err = <-m.failed:
+			log.Printf("Shutting down: %v", err)
+		}
+	}
+
+	// Restore the default signal handling so a second signal kills the process
+	stop()
+	return errors.Join(err, m.stop(started))
+}
+
+// start starts the hooks in order and returns the ones to stop, those registered before a hook that
+// failed to start
+func (m *Manager) start(ctx context.Context) ([]Hook, error) {
+	for i, hook := range m.hooks {
+		if hook.Start == nil {
+			continue
+		}
+		if err := hook.Start(ctx); err != nil {
+			return m.hooks[:i], fmt.Errorf("start %s: %w", hook.Name, err)
+		}
+	}
+	return m.hooks, nil
+}
+
+// stop stops the hooks in reverse order. Every hook is stopped even after the deadline, so
+// resources such as the database pool are always released.
+func (m *Manager) stop(hooks []Hook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if hook.Stop == nil {
+			continue
+		}
+		if err := hook.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", hook.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
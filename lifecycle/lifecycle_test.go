@@ -0,0 +1,155 @@
+package lifecycle_test
+
+import (
+	"backend/lifecycle"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder keeps the order hooks are started and stopped in
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+// hook returns a hook recording its start and stop, failing to start with startErr if set
+func (r *recorder) hook(name string, startErr error) lifecycle.Hook {
+	return lifecycle.Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			if startErr != nil {
+				return startErr
+			}
+			r.record("start " + name)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	errStopped := errors.New("server stopped")
+	errBroken := errors.New("broken")
+
+	tests := []struct {
+		name       string
+		failing    string // Hook that fails to start
+		wantEvents []string
+		wantErr    error
+	}{
+		{
+			name:       "start in order and stop in reverse after Fail",
+			wantEvents: []string{"start database", "start worker", "start server", "stop server", "stop worker", "stop database"},
+			wantErr:    errStopped,
+		},
+		{
+			name:       "stop only the hooks started before a failed start",
+			failing:    "worker",
+			wantEvents: []string{"start database", "stop database"},
+			wantErr:    errBroken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r recorder
+			m := lifecycle.New(time.Second)
+			for _, name := range []string{"database", "worker", "server"} {
+				var startErr error
+				if name == tt.failing {
+					startErr = errBroken
+				}
+				m.Append(r.hook(name, startErr))
+			}
+			// Once everything runs, a component stops unexpectedly
+			m.Append(lifecycle.Hook{Name: "monitor", Start: func(context.Context) error {
+				go m.Fail(errStopped)
+				return nil
+			}})
+
+			err := m.Run()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got := r.list(); !slices.Equal(got, tt.wantEvents) {
+				t.Fatalf("got events %q, want %q", got, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestRunStopsWorkers(t *testing.T) {
+	m := lifecycle.New(time.Second)
+	stopped := make(chan struct{})
+	m.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+	m.Append(lifecycle.Hook{Name: "monitor", Start: func(context.Context) error {
+		go m.Fail(errors.New("done"))
+		return nil
+	}})
+
+	m.Run()
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("Run returned before the worker stopped")
+	}
+}
+
+func TestRunShutdownDeadline(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	var r recorder
+	m := lifecycle.New(timeout)
+	m.Append(r.hook("database", nil))
+	// A worker ignoring its context holds shutdown until the deadline
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	m.Go("stuck", func(context.Context) { <-release })
+	m.Append(lifecycle.Hook{
+		Name: "server",
+		Stop: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	m.Append(lifecycle.Hook{Name: "monitor", Start: func(context.Context) error {
+		go m.Fail(errors.New("done"))
+		return nil
+	}})
+
+	began := time.Now()
+	err := m.Run()
+	if elapsed := time.Since(began); elapsed > 10*timeout {
+		t.Fatalf("shutdown took %s, want about %s", elapsed, timeout)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "stop server") || !strings.Contains(err.Error(), "stop stuck") {
+		t.Fatalf("got error %v, want the server and the stuck worker past the deadline", err)
+	}
+
+	// Hooks after the deadline are still stopped
+	if got := r.list(); !slices.Equal(got, []string{"start database", "stop database"}) {
+		t.Fatalf("got events %q, want the database stopped", got)
+	}
+}
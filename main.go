@@ -1,66 +1,145 @@
 package main
 
 import (
+	"backend/config"
 	"backend/database"
+	"backend/lifecycle"
+	"backend/migrations"
 
 	"backend/routes"
 	"backend/utils"
 
+	"context"
 	"log"
+	"net"
+	"os"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/joho/godotenv"
 )
 
 func main() {
 
-	err := godotenv.Load()
+	// Load and validate the configuration from the defaults, .env, config file and environment
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatalf("invalid configuration:\n%v", err)
 	}
 
-	//cleanup expired token
-	utils.StartCleanupRoutine()
+	// Run a subcommand such as `backend migrate up` or `backend seed` instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Load the JWT signing keys and token lifetimes
+	if err := utils.LoadSigningKeys(cfg.Auth.JWT); err != nil {
+		log.Fatal(err)
+	}
+	utils.SetTokenLifetimes(cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+
+	// Load the OpenID Connect providers users can sign in with
+	if err := utils.LoadOIDCProviders(cfg.Auth.OIDC); err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the failed login limits
+	utils.SetLoginThrottleConfig(cfg.Auth.LoginThrottle)
+
+	// Load the password policy and hashing parameters
+	if err := utils.LoadPasswordPolicy(cfg.Auth.PasswordPolicy); err != nil {
+		log.Fatal(err)
+	}
+	utils.SetArgon2Params(cfg.Auth.Argon2)
+
+	// Load the email verification, cookie session and mail settings
+	utils.SetEmailVerificationConfig(cfg.Auth.EmailVerification)
+	utils.SetWebSessionConfig(cfg.Auth.Session)
+	utils.SetMailConfig(cfg.Mail)
 
 	// Create a new Fiber app with the custom validator
 	app := fiber.New(fiber.Config{
 		StructValidator: utils.Validator, // Use the initialized custom Validator
 	})
 
-	// Initialize the database connection
-	db := database.InitDB()
+	// Components are started in this order and stopped in reverse on SIGINT or SIGTERM
+	lc := lifecycle.New(cfg.Server.ShutdownTimeout)
+
+	// Initialize the database connection, the pool is closed last
+	db := database.InitDB(cfg.Database)
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	lc.Append(lifecycle.Hook{
+		Name: "database",
+		Stop: func(context.Context) error { return sqlDB.Close() },
+	})
 
-	// Perform auto migration
-	// db.AutoMigrate(
-	// 	&model.User{},
-	// 	&model.AccountDetail{},
-	// 	&model.History{},
-	// 	&model.Location{}, // Added Location model
-	// 	&model.Manager{},  // Added Manager model
-	// 	&model.Branch{},   // Added Branch model
-	// )
+	// Apply pending schema migrations, or refuse to start on an outdated schema
+	if cfg.Database.AutoMigrate {
+		if _, err := migrations.Up(db); err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+	} else if pending, err := migrations.Pending(db); err != nil {
+		log.Fatalf("failed to check migrations: %v", err)
+	} else if pending > 0 {
+		log.Fatalf("%d pending migrations, run `backend migrate up`", pending)
+	}
 
-	// // Insert 50-100 records
-	// for i := 1; i <= 100; i++ {
-	// 	branchData := utils.GenerateRandomBranchData(i)
+	// Keep active tokens and browser sessions in the database unless the in-memory store is requested
+	if cfg.Auth.TokenStore != "memory" {
+		sessions, err := database.NewStorage(db, "web_sessions")
+		if err != nil {
+			log.Fatalf("failed to open session storage: %v", err)
+		}
+		utils.SetTokenStore(utils.NewDatabaseTokenStore(db))
+		utils.SetWebSessionStorage(sessions)
+	}
 
-	// 	branch := &model.Branch{
-	// 		BranchData: branchData,
-	// 	}
+	// Create the built-in roles and grant admin to the configured admin email
+	if err := database.SeedRoles(db, cfg.Auth.AdminEmail); err != nil {
+		log.Fatalf("failed to seed roles: %v", err)
+	}
 
-	// 	// Insert the branch record into the database
-	// 	if err := db.Create(&branch).Error; err != nil {
-	// 		log.Fatalf("failed to insert branch: %v", err)
-	// 	}
-	// 	fmt.Printf("Inserted branch ID: %d\n", branch.ID)
-	// }
+	// Remove expired tokens and accounts that were never verified
+	lc.Go("token cleanup", utils.CleanupExpiredTokens)
+	lc.Go("unverified account cleanup", func(ctx context.Context) {
+		utils.RunUnverifiedCleanup(ctx, db)
+	})
 
-	// fmt.Println("Inserted 100 branches successfully.")
+	// Let emails sent in the background finish before the database is closed
+	lc.Append(lifecycle.Hook{
+		Name: "background tasks",
+		Stop: utils.WaitBackground,
+	})
 
 	// Setup routes
-	routes.SetupRoutes(app, db)
+	routes.SetupRoutes(app, db, cfg)
 	routes.ProtectedRoutes(app, db)
+	routes.AdminRoutes(app, db)
+
+	// Start the Fiber app, shutdown stops accepting connections and drains in-flight requests
+	lc.Append(lifecycle.Hook{
+		Name: "http server",
+		Start: func(context.Context) error {
+			ln, err := net.Listen("tcp", cfg.Server.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := app.Listener(ln); err != nil {
+					lc.Fail(err)
+				}
+			}()
+			return nil
+		},
+		Stop: app.ShutdownWithContext,
+	})
 
-	// Start the Fiber app
-	log.Fatal(app.Listen(":3000"))
+	if err := lc.Run(); err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Server stopped")
 }
@@ -1,18 +1,51 @@
 package middleware
 
 import (
+	"backend/model"
 	"backend/utils"
+	"errors"
 	"log"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
-// AuthMiddleware checks for a valid JWT token
-func AuthMiddleware() fiber.Handler {
+// AuthMiddleware accepts a JWT bearer token, a session cookie or an X-API-Key header and stores
+// the authenticated principal in c.Locals("principal"). Impersonated requests are audited.
+func AuthMiddleware(db *gorm.DB) fiber.Handler {
 	return func(c fiber.Ctx) error {
+		// Service-to-service callers authenticate with an API key
+		if apiKey := c.Get("X-API-Key"); apiKey != "" {
+			principal, err := utils.AuthenticateAPIKey(db, apiKey)
+			if err != nil {
+				log.Printf("API key rejected: %v", err)
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired API key"})
+			}
+
+			c.Locals("principal", principal)
+			return c.Next()
+		}
+
 		// Get the token from the Authorization header
 		token := c.Get("Authorization")
 
+		// Browsers logged in with ?mode=session send the session cookie instead, and a
+		// CSRF token on state-changing requests
+		if token == "" && c.Cookies(utils.WebSessionCookie) != "" {
+			principal, csrfToken, err := utils.WebSessionPrincipal(c, db)
+			if err != nil {
+				log.Printf("Session rejected: %v", err)
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired session"})
+			}
+			if err := utils.CheckCSRF(c, csrfToken); err != nil {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+			}
+
+			c.Locals("principal", principal)
+			c.Locals("csrf_token", csrfToken)
+			return c.Next()
+		}
+
 		// Check if the token is present and extract the Bearer token
 		if token == "" || len(token) < 7 || token[:7] != "Bearer " {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No token provided"})
@@ -21,18 +54,44 @@ func AuthMiddleware() fiber.Handler {
 		// Extract the actual token
 		jwtToken := token[7:]
 
-		// Log the token being checked
-		log.Printf("Checking token: %s", jwtToken)
-
 		// Validate the token
+		// The cause is only logged, the response does not tell callers why a token was refused
 		claims, err := utils.ValidateToken(jwtToken)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+			log.Printf("Token rejected: %v", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
 		}
 
-		// Store user claims in context for later use
+		principal, err := utils.PrincipalFromClaims(claims)
+		if err != nil {
+			log.Printf("Token rejected: %v", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+		}
+
+		// Store user claims and the principal in context for later use
 		c.Locals("claims", claims)
+		c.Locals("principal", principal)
 
-		return c.Next()
+		if !principal.Impersonated() {
+			return c.Next()
+		}
+
+		// Every request made while impersonating is written to the audit log
+		err = c.Next()
+		status := c.Response().StatusCode()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		}
+		utils.RecordAudit(db, model.AuditLog{
+			ActorID: principal.ActorID,
+			UserID:  principal.UserID,
+			Action:  model.AuditImpersonatedRequest,
+			Method:  c.Method(),
+			Path:    c.OriginalURL(),
+			Status:  status,
+			IP:      c.IP(),
+		})
+		return err
 	}
 }
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"backend/utils"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// ClientAuthMiddleware authenticates services calling the OAuth endpoints with an API key, sent
+// either as HTTP Basic client credentials (the key prefix as client_id and the rest of the key
+// as client_secret) or in the X-API-Key header. Failures use the RFC 6749 invalid_client error.
+func ClientAuthMiddleware(db *gorm.DB) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		apiKey := c.Get("X-API-Key")
+		if apiKey == "" {
+			clientID, clientSecret, ok := basicCredentials(c.Get(fiber.HeaderAuthorization))
+			if ok {
+				apiKey = clientID + "_" + clientSecret
+			}
+		}
+		if apiKey == "" {
+			return invalidClient(c)
+		}
+
+		principal, err := utils.AuthenticateAPIKey(db, apiKey)
+		if err != nil {
+			return invalidClient(c)
+		}
+
+		c.Locals("principal", principal)
+		return c.Next()
+	}
+}
+
+// basicCredentials decodes a Basic authorization header, whose parts are form-encoded
+func basicCredentials(header string) (string, string, bool) {
+	if len(header) < 6 || !strings.EqualFold(header[:6], "Basic ") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(header[6:])
+	if err != nil {
+		return "", "", false
+	}
+	clientID, clientSecret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+	clientID, err = url.QueryUnescape(clientID)
+	if err != nil {
+		return "", "", false
+	}
+	clientSecret, err = url.QueryUnescape(clientSecret)
+	if err != nil {
+		return "", "", false
+	}
+	return clientID, clientSecret, clientID != "" && clientSecret != ""
+}
+
+// invalidClient rejects a request whose client could not be authenticated
+func invalidClient(c fiber.Ctx) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid_client"})
+}
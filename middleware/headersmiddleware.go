@@ -10,7 +10,7 @@ func HeadersMiddleware() fiber.Handler {
 		// Handle CORS
 		c.Set("Access-Control-Allow-Origin", "*") // Change to your allowed origins
 		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-CSRF-Token")
 
 		// Set Content-Type header for JSON responses
 		c.Set("Content-Type", "application/json")
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// DenyImpersonation blocks sensitive actions, like changing credentials, for impersonation
+// tokens. It must run after AuthMiddleware.
+func DenyImpersonation() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		principal, ok := c.Locals("principal").(*utils.Principal)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No token provided"})
+		}
+
+		if principal.Impersonated() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not allowed while impersonating a user"})
+		}
+
+		return c.Next()
+	}
+}
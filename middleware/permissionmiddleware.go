@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RequirePermission allows the request only when the principal is granted the permission.
+// It must run after AuthMiddleware.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		principal, ok := c.Locals("principal").(*utils.Principal)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No token provided"})
+		}
+
+		if !principal.HasPermission(permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Insufficient permissions"})
+		}
+
+		return c.Next()
+	}
+}
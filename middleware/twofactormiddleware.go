@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RequireTwoFactor allows the request only for logins that passed two-factor authentication.
+// API keys and impersonation tokens never pass it. It must run after AuthMiddleware.
+func RequireTwoFactor() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		principal, ok := c.Locals("principal").(*utils.Principal)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No token provided"})
+		}
+
+		if !principal.MFA {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Two-factor authentication required"})
+		}
+
+		return c.Next()
+	}
+}
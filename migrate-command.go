@@ -0,0 +1,83 @@
+package main
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/migrations"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// migrateUsage describes the migrate subcommands
+const migrateUsage = "usage: backend migrate up | down [-steps N] | status | create [-dir DIR] <name>"
+
+// runMigrate applies, rolls back, lists or creates schema migrations
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(database.InitDB(cfg.Database))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", len(applied))
+		return nil
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "number of migrations to roll back")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return errors.New("-steps must be at least 1")
+		}
+		rolledBack, err := migrations.Down(database.InitDB(cfg.Database), *steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d migrations\n", len(rolledBack))
+		return nil
+
+	case "status":
+		statuses, err := migrations.StatusOf(database.InitDB(cfg.Database))
+		if err != nil {
+			return err
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return writer.Flush()
+
+	case "create":
+		flags := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+		dir := flags.String("dir", "migrations/sql", "directory of the SQL migrations")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return errors.New(migrateUsage)
+		}
+		paths, err := migrations.Create(*dir, flags.Arg(0))
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return err
+
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// activeToken is the active_tokens layout of this migration, frozen like the baseline models
+type activeToken struct {
+	TokenKey  string    `gorm:"column:token_key;primaryKey;size:64"`
+	UserID    uint      `gorm:"column:user_id;index;not null"`
+	Family    string    `gorm:"column:family;size:255;index"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index;not null"`
+}
+
+// storageEntry is the key-value layout active_tokens had before this migration
+type storageEntry struct {
+	Key       string `gorm:"column:k;primaryKey;size:64"`
+	Value     []byte `gorm:"column:v;not null"`
+	ExpiresAt int64  `gorm:"column:e;not null;default:0"`
+}
+
+func init() {
+	Register(Migration{
+		Version: 20261018150000,
+		Name:    "active_tokens",
+		// The key-value table cannot be searched by user or family, so it is replaced. Access
+		// tokens issued before the upgrade stop working, clients get new ones with their refresh token.
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable("active_tokens"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateTable(&activeToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable("active_tokens"); err != nil {
+				return err
+			}
+			return tx.Table("active_tokens").Migrator().CreateTable(&storageEntry{})
+		},
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"backend/migrations/baseline"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Migration{
+		Version: 20261018000000,
+		Name:    "baseline",
+		// Creating the tables with AutoMigrate adopts databases that were set up by earlier
+		// builds without touching their data
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(baseline.Models...)
+		},
+		Down: func(tx *gorm.DB) error {
+			tables := []any{"user_roles", "role_permissions", "service_account_roles"}
+			for i := len(baseline.Models) - 1; i >= 0; i-- {
+				tables = append(tables, baseline.Models[i])
+			}
+			return tx.Migrator().DropTable(tables...)
+		},
+	})
+}
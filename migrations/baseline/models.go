@@ -0,0 +1,233 @@
+// Package baseline is a frozen copy of the models as they were when versioned migrations were
+// introduced. The baseline migration creates its tables from these structs so later changes
+// to the model package do not change what it creates. Never edit them, add a migration instead.
+package baseline
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type User struct {
+	ID            uint          `gorm:"primaryKey;column:id"`
+	Name          string        `gorm:"column:name;not null"`
+	Age           int           `gorm:"column:age;not null"`
+	Email         string        `gorm:"column:email;unique;not null"`
+	Password      string        `gorm:"column:password;not null"`
+	IsVerified    bool          `gorm:"column:is_verified;default:false"`
+	AccountDetail AccountDetail `gorm:"foreignKey:UserID"`
+	History       History       `gorm:"foreignKey:UserID"`
+	Roles         []Role        `gorm:"many2many:user_roles"`
+	CreatedAt     time.Time
+}
+
+type AccountDetail struct {
+	ID      uint    `gorm:"primaryKey"`
+	UserID  uint    `gorm:"index"`
+	Balance float64 `gorm:"column:balance;default:100"`
+}
+
+type History struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index"`
+	Action    string `gorm:"column:action;default:account successfully created"`
+	CreatedAt time.Time
+}
+
+type Branch struct {
+	ID         uint `gorm:"primaryKey"`
+	BranchData datatypes.JSON
+}
+
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"index;not null"`
+	Family    string     `gorm:"column:family;index;not null"`
+	TokenHash string     `gorm:"column:token_hash;size:255;uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	RotatedAt *time.Time `gorm:"column:rotated_at"`
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+	CreatedAt time.Time
+}
+
+type Permission struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"column:name;unique;not null"`
+}
+
+type Role struct {
+	ID          uint         `gorm:"primaryKey"`
+	Name        string       `gorm:"column:name;unique;not null"`
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+}
+
+type PasswordReset struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"index;not null"`
+	TokenHash string     `gorm:"column:token_hash;size:255;uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time
+}
+
+type LoginThrottle struct {
+	ID            uint       `gorm:"primaryKey"`
+	Key           string     `gorm:"column:throttle_key;size:255;uniqueIndex;not null"`
+	Failures      int        `gorm:"column:failures;not null;default:0"`
+	LastFailureAt time.Time  `gorm:"column:last_failure_at"`
+	LockedUntil   *time.Time `gorm:"column:locked_until"`
+}
+
+type TwoFactor struct {
+	ID           uint       `gorm:"primaryKey"`
+	UserID       uint       `gorm:"uniqueIndex;not null"`
+	Secret       string     `gorm:"column:secret;not null"`
+	Enabled      bool       `gorm:"column:enabled;default:false"`
+	LastUsedStep int64      `gorm:"column:last_used_step;default:0"`
+	EnabledAt    *time.Time `gorm:"column:enabled_at"`
+	CreatedAt    time.Time
+}
+
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"index;not null"`
+	CodeHash  string     `gorm:"column:code_hash;size:255;uniqueIndex;not null"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time
+}
+
+type Session struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index;not null"`
+	Family     string `gorm:"column:family;size:255;uniqueIndex;not null"`
+	UserAgent  string `gorm:"column:user_agent"`
+	IP         string `gorm:"column:ip"`
+	CreatedAt  time.Time
+	LastSeenAt time.Time  `gorm:"column:last_seen_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+}
+
+type ServiceAccount struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"column:name;unique;not null"`
+	Roles     []Role `gorm:"many2many:service_account_roles"`
+	CreatedAt time.Time
+}
+
+type APIKey struct {
+	ID               uint       `gorm:"primaryKey"`
+	UserID           *uint      `gorm:"index"`
+	ServiceAccountID *uint      `gorm:"index"`
+	Name             string     `gorm:"column:name;not null"`
+	Prefix           string     `gorm:"column:prefix;size:255;uniqueIndex;not null"`
+	KeyHash          string     `gorm:"column:key_hash;not null"`
+	Scopes           []string   `gorm:"column:scopes;serializer:json"`
+	ExpiresAt        *time.Time `gorm:"column:expires_at"`
+	LastUsedAt       *time.Time `gorm:"column:last_used_at"`
+	CreatedAt        time.Time
+}
+
+type OIDCLogin struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"column:state_hash;size:255;uniqueIndex;not null"`
+	Provider     string    `gorm:"column:provider;not null"`
+	Nonce        string    `gorm:"column:nonce;not null"`
+	CodeVerifier string    `gorm:"column:code_verifier;not null"`
+	ExpiresAt    time.Time `gorm:"column:expires_at;not null"`
+	CreatedAt    time.Time
+}
+
+type UserIdentity struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	Provider  string `gorm:"column:provider;size:255;uniqueIndex:idx_identity_provider_subject;not null"`
+	Subject   string `gorm:"column:subject;size:255;uniqueIndex:idx_identity_provider_subject;not null"`
+	Email     string `gorm:"column:email"`
+	CreatedAt time.Time
+}
+
+type EmailVerification struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"index;not null"`
+	TokenHash string     `gorm:"column:token_hash;size:255;uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time
+}
+
+type EmailChange struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"index;not null"`
+	NewEmail  string     `gorm:"column:new_email;not null"`
+	TokenHash string     `gorm:"column:token_hash;size:255;uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time
+}
+
+type PasswordHistory struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	Hash      string `gorm:"column:hash;not null"`
+	CreatedAt time.Time
+}
+
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey"`
+	ActorID   uint      `gorm:"index;not null"`
+	UserID    uint      `gorm:"index;not null"`
+	Action    string    `gorm:"column:action;not null"`
+	Method    string    `gorm:"column:method"`
+	Path      string    `gorm:"column:path"`
+	Status    int       `gorm:"column:status"`
+	IP        string    `gorm:"column:ip"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+type MagicLink struct {
+	ID          uint       `gorm:"primaryKey"`
+	UserID      uint       `gorm:"index;not null"`
+	TokenID     string     `gorm:"column:token_id;size:255;uniqueIndex;not null"`
+	BrowserHash string     `gorm:"column:browser_hash;not null"`
+	ExpiresAt   time.Time  `gorm:"column:expires_at;not null"`
+	UsedAt      *time.Time `gorm:"column:used_at"`
+	CreatedAt   time.Time
+}
+
+type SecurityEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index"`
+	Email     string    `gorm:"column:email;index"`
+	Type      string    `gorm:"column:type;not null"`
+	Detail    string    `gorm:"column:detail"`
+	IP        string    `gorm:"column:ip"`
+	UserAgent string    `gorm:"column:user_agent"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// Models lists the baseline tables in creation order
+var Models = []any{
+	&User{},
+	&AccountDetail{},
+	&History{},
+	&Branch{},
+	&RefreshToken{},
+	&Permission{},
+	&Role{},
+	&PasswordReset{},
+	&LoginThrottle{},
+	&TwoFactor{},
+	&RecoveryCode{},
+	&Session{},
+	&ServiceAccount{},
+	&APIKey{},
+	&OIDCLogin{},
+	&UserIdentity{},
+	&EmailVerification{},
+	&EmailChange{},
+	&PasswordHistory{},
+	&AuditLog{},
+	&MagicLink{},
+	&SecurityEvent{},
+}
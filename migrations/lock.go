@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey identifies the migration lock among the application's advisory locks
+const lockKey = 72_616_201
+
+// lockName is the MySQL named lock equivalent of lockKey
+const lockName = "backend_schema_migrations"
+
+// lock takes the migration lock on the connection, waiting up to LockTimeout for another
+// instance to release it. Postgres advisory locks and MySQL named locks are held by the
+// session, so the connection must stay pinned until unlock is called. SQLite needs no lock,
+// its transactions already exclude other writers.
+func lock(conn *gorm.DB) (func(), error) {
+	var tryLock, unlock string
+	var arg any
+	switch conn.Dialector.Name() {
+	case "postgres":
+		tryLock, unlock, arg = "SELECT pg_try_advisory_lock(?)", "SELECT pg_advisory_unlock(?)", lockKey
+	case "mysql":
+		tryLock, unlock, arg = "SELECT GET_LOCK(?, 0) = 1", "SELECT RELEASE_LOCK(?)", lockName
+	default:
+		return func() {}, nil
+	}
+
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		var locked bool
+		if err := conn.Raw(tryLock, arg).Scan(&locked).Error; err != nil {
+			return nil, err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrMigrationLocked
+		}
+		log.Print("waiting for another instance to finish migrating")
+		time.Sleep(time.Second)
+	}
+
+	return func() {
+		if err := conn.Exec(unlock, arg).Error; err != nil {
+			log.Printf("failed to release migration lock: %v", err)
+		}
+	}, nil
+}
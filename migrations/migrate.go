@@ -0,0 +1,209 @@
+// Package migrations evolves the database schema with ordered, versioned Go and SQL migrations.
+// Applied versions are recorded in schema_migrations and an advisory lock keeps two instances
+// from migrating at the same time.
+package migrations
+
+import (
+	"backend/model"
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one schema change. Up and Down run inside a transaction together with the
+// schema_migrations bookkeeping.
+type Migration struct {
+	Version int64  // Timestamp such as 20261018120000, migrations run in version order
+	Name    string // Short snake_case description
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// Status is a known migration and when it was applied, AppliedAt is nil while pending
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Define custom error messages
+var (
+	ErrMigrationLocked = errors.New("another instance is migrating the database")
+	ErrNoDown          = errors.New("migration cannot be rolled back")
+)
+
+// LockTimeout is how long to wait for another instance to finish migrating
+var LockTimeout = 2 * time.Minute
+
+// registered holds the Go migrations added with Register
+var registered []Migration
+
+// Register adds a Go migration, called from the init function of its file
+func Register(migration Migration) {
+	registered = append(registered, migration)
+}
+
+// All returns the Go and SQL migrations in version order
+func All() ([]Migration, error) {
+	sqlMigrations, err := loadSQLMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	all := append(slices.Clone(registered), sqlMigrations...)
+	slices.SortFunc(all, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	for i := 1; i < len(all); i++ {
+		if all[i].Version == all[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", all[i-1].Name, all[i].Name, all[i].Version)
+		}
+	}
+	return all, nil
+}
+
+// Up applies every pending migration and returns the ones it applied
+func Up(db *gorm.DB) ([]Migration, error) {
+	var applied []Migration
+	err := withLock(db, func(conn *gorm.DB) error {
+		statuses, err := statuses(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			if status.AppliedAt != nil {
+				continue
+			}
+			migration := status.Migration
+			log.Printf("applying migration %d %s", migration.Version, migration.Name)
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&model.SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and returns them
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := withLock(db, func(conn *gorm.DB) error {
+		statuses, err := statuses(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(statuses) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+			if statuses[i].AppliedAt == nil {
+				continue
+			}
+			migration := statuses[i].Migration
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, ErrNoDown)
+			}
+			log.Printf("rolling back migration %d %s", migration.Version, migration.Name)
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&model.SchemaMigration{}, migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of migration %d %s failed: %w", migration.Version, migration.Name, err)
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// StatusOf lists every known migration and whether it was applied. It only reads, so it can
+// run while another instance holds the migration lock.
+func StatusOf(db *gorm.DB) ([]Status, error) {
+	return statuses(db)
+}
+
+// Pending counts the migrations that have not been applied yet
+func Pending(db *gorm.DB) (int, error) {
+	statuses, err := StatusOf(db)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// statuses joins the known migrations with the applied versions. Applied versions without a
+// migration, for example from a newer build, are an error. A database without the
+// schema_migrations table has nothing applied.
+func statuses(db *gorm.DB) ([]Status, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []model.SchemaMigration
+	if db.Migrator().HasTable(&model.SchemaMigration{}) {
+		if err := db.Order("version").Find(&records).Error; err != nil {
+			return nil, err
+		}
+	}
+	applied := map[int64]time.Time{}
+	for _, record := range records {
+		applied[record.Version] = record.AppliedAt
+	}
+
+	result := make([]Status, 0, len(all))
+	for _, migration := range all {
+		status := Status{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		result = append(result, status)
+	}
+	for version := range applied {
+		return nil, fmt.Errorf("database has migration %d applied, which this build does not know", version)
+	}
+	return result, nil
+}
+
+// withLock runs fn on a single connection holding the migration lock, after making sure the
+// schema_migrations table exists
+func withLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		// Start every statement fresh while keeping the pinned connection
+		conn = conn.Session(&gorm.Session{NewDB: true})
+
+		unlock, err := lock(conn)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if err := conn.AutoMigrate(&model.SchemaMigration{}); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
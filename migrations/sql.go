@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sqlFiles holds the SQL migrations, named <version>_<name>.up.sql with an optional .down.sql
+//
+//go:embed sql
+var sqlFiles embed.FS
+
+// sqlFileName matches the name of a SQL migration file
+var sqlFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// loadSQLMigrations builds a migration from each up file and its down file
+func loadSQLMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(sqlFiles, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	var versions []int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := sqlFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("SQL migration %s must be named <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("SQL migration %s: %w", entry.Name(), err)
+		}
+		data, err := sqlFiles.ReadFile("sql/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+			versions = append(versions, version)
+		}
+		if match[3] == "up" {
+			migration.Up = execSQL(string(data))
+		} else {
+			migration.Down = execSQL(string(data))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		migration := byVersion[version]
+		if migration.Up == nil {
+			return nil, fmt.Errorf("SQL migration %d %s has no up file", version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	return migrations, nil
+}
+
+// execSQL runs the statements of a migration file
+func execSQL(statements string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(statements).Error
+	}
+}
+
+// migrationName turns a description into the snake_case name used in file names
+var migrationName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create writes empty up and down files for a new SQL migration to dir and returns their paths.
+// The files are embedded, so the binary has to be rebuilt before it can apply them.
+func Create(dir string, description string) ([]string, error) {
+	name := strings.Trim(migrationName.ReplaceAllString(strings.ToLower(description), "_"), "_")
+	if name == "" {
+		return nil, errors.New("migration name must contain letters or digits")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %s: %s (%s)\n", version, name, direction)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return paths, err
+		}
+		_, err = file.WriteString(content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
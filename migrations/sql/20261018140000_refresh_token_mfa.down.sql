@@ -0,0 +1,2 @@
+-- 20261018140000: refresh_token_mfa (down)
+ALTER TABLE refresh_tokens DROP COLUMN mfa;
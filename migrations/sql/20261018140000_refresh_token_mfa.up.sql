@@ -0,0 +1,2 @@
+-- 20261018140000: refresh_token_mfa (up)
+ALTER TABLE refresh_tokens ADD COLUMN mfa BOOLEAN NOT NULL DEFAULT FALSE;
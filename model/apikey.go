@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+type ServiceAccount struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"column:name;unique;not null" json:"name"`
+	Roles     []Role    `gorm:"many2many:service_account_roles" json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type APIKey struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	UserID           *uint      `gorm:"index" json:"user_id,omitempty"`            // Set for keys owned by a user
+	ServiceAccountID *uint      `gorm:"index" json:"service_account_id,omitempty"` // Set for keys owned by a service account
+	Name             string     `gorm:"column:name;not null" json:"name"`
+	Prefix           string     `gorm:"column:prefix;size:255;uniqueIndex;not null" json:"prefix"` // Public part of the key used for lookup
+	KeyHash          string     `gorm:"column:key_hash;not null" json:"-"`                         // SHA-256 of the full key
+	Scopes           []string   `gorm:"column:scopes;serializer:json" json:"scopes"`
+	ExpiresAt        *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	LastUsedAt       *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type ServiceAccountRequest struct {
+	Name  string   `json:"name" validate:"required,max=100"`
+	Roles []string `json:"roles"`
+}
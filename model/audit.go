@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Audit log actions
+const (
+	AuditImpersonationStart  = "impersonation.start"
+	AuditImpersonatedRequest = "impersonation.request"
+)
+
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ActorID   uint      `gorm:"index;not null" json:"actor_id"` // The admin acting
+	UserID    uint      `gorm:"index;not null" json:"user_id"`  // The user acted as
+	Action    string    `gorm:"column:action;not null" json:"action"`
+	Method    string    `gorm:"column:method" json:"method"`
+	Path      string    `gorm:"column:path" json:"path"`
+	Status    int       `gorm:"column:status" json:"status"`
+	IP        string    `gorm:"column:ip" json:"ip"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+type EmailChange struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	NewEmail  string     `gorm:"column:new_email;not null" json:"new_email"`
+	TokenHash string     `gorm:"column:token_hash;size:255;uniqueIndex;not null" json:"-"` // SHA-256 of the token emailed to the new address
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ChangeEmailRequest struct {
+	Password string `json:"password" validate:"required"`
+	NewEmail string `json:"new_email" validate:"required,email"`
+}
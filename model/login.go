@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+type LoginThrottle struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Key           string     `gorm:"column:throttle_key;size:255;uniqueIndex;not null" json:"key"` // "account:<email>" or "ip:<address>"
+	Failures      int        `gorm:"column:failures;not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"column:last_failure_at" json:"last_failure_at"`
+	LockedUntil   *time.Time `gorm:"column:locked_until" json:"locked_until"`
+}
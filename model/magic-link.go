@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type MagicLink struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	TokenID     string     `gorm:"column:token_id;size:255;uniqueIndex;not null" json:"-"` // jti of the signed link token
+	BrowserHash string     `gorm:"column:browser_hash;not null" json:"-"`                  // SHA-256 of the requesting browser's cookie
+	ExpiresAt   time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	UsedAt      *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type OIDCLogin struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	StateHash    string    `gorm:"column:state_hash;size:255;uniqueIndex;not null" json:"-"` // SHA-256 of the state parameter
+	Provider     string    `gorm:"column:provider;not null" json:"provider"`
+	Nonce        string    `gorm:"column:nonce;not null" json:"-"`
+	CodeVerifier string    `gorm:"column:code_verifier;not null" json:"-"` // PKCE verifier sent with the code exchange
+	ExpiresAt    time.Time `gorm:"column:expires_at;not null" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Provider  string    `gorm:"column:provider;size:255;uniqueIndex:idx_identity_provider_subject;not null" json:"provider"`
+	Subject   string    `gorm:"column:subject;size:255;uniqueIndex:idx_identity_provider_subject;not null" json:"subject"` // The provider's "sub" claim
+	Email     string    `gorm:"column:email" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
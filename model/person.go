@@ -3,20 +3,21 @@ package model
 import "time"
 
 type User struct {
-	ID                uint          `gorm:"primaryKey;column:id" json:"id"`
-	Name              string        `gorm:"column:name;not null" validate:"required,min=8,max=12" json:"name"`
-	Age               int           `gorm:"column:age;not null" validate:"required,gte=18,lte=65" json:"age"`
-	Email             string        `gorm:"column:email;unique;not null" validate:"required,email" json:"email"`
-	Password          string        `gorm:"column:password;not null" validate:"required,min=8,max=12" json:"password"`
-	IsVerified        bool          `gorm:"column:is_verified;default:false" json:"is_verified"`                      // New field
-	VerificationToken string        `gorm:"column:verification_token;default:tokenlicious" json:"verification_token"` // New field
-	AccountDetail     AccountDetail `gorm:"foreignKey:UserID" json:"account_details"`
-	History           History       `gorm:"foreignKey:UserID" json:"histories"`
+	ID            uint          `gorm:"primaryKey;column:id" json:"id"`
+	Name          string        `gorm:"column:name;not null" validate:"required,min=8,max=12" json:"name"`
+	Age           int           `gorm:"column:age;not null" validate:"required,gte=18,lte=65" json:"age"`
+	Email         string        `gorm:"column:email;unique;not null" validate:"required,email" json:"email"`
+	Password      string        `gorm:"column:password;not null" validate:"required" json:"password"` // Checked against utils.ValidatePassword
+	IsVerified    bool          `gorm:"column:is_verified;default:false" json:"is_verified"`          // New field
+	AccountDetail AccountDetail `gorm:"foreignKey:UserID" json:"account_details"`
+	History       History       `gorm:"foreignKey:UserID" json:"histories"`
+	Roles         []Role        `gorm:"many2many:user_roles" json:"roles"`
+	CreatedAt     time.Time     `json:"created_at"`
 }
 
 type UserLogin struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8,max=12"`
+	Password string `json:"password" validate:"required"`
 }
 
 type AccountDetail struct {
@@ -0,0 +1,42 @@
+package model
+
+// Built-in role names
+const (
+	RoleAdmin       = "admin"
+	RoleUser        = "user"
+	RoleTokenClient = "token-client" // For services introspecting and revoking tokens
+)
+
+// Permission names checked by middleware.RequirePermission
+const (
+	PermissionPersonCreate    = "person:create"
+	PermissionPersonRead      = "person:read"
+	PermissionPersonUpdate    = "person:update"
+	PermissionPersonDelete    = "person:delete"
+	PermissionPersonExport    = "person:export"
+	PermissionBranchRead      = "branch:read"
+	PermissionBranchCreate    = "branch:create"
+	PermissionBranchDelete    = "branch:delete"
+	PermissionRoleManage      = "role:manage"
+	PermissionSessionManage   = "session:manage"
+	PermissionAPIKeyManage    = "apikey:manage"
+	PermissionImpersonate     = "user:impersonate"
+	PermissionAuditRead       = "audit:read"
+	PermissionTokenIntrospect = "token:introspect"
+	PermissionTokenRevoke     = "token:revoke"
+)
+
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"column:name;unique;not null" json:"name"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+}
+
+type Permission struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"column:name;unique;not null" json:"name"`
+}
+
+type RoleAssignment struct {
+	Role string `json:"role" validate:"required"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// SchemaMigration records a migration that has been applied to the database
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
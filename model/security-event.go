@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Security event types shown in a user's security timeline
+const (
+	SecurityLoginSuccess       = "login.success"
+	SecurityLoginFailure       = "login.failure"
+	SecurityLoginLockout       = "login.lockout"
+	SecurityLoginNewDevice     = "login.new_device"
+	SecurityPasswordChange     = "password.change"
+	SecurityPasswordConfirm    = "password.confirm_failure" // Wrong current password when changing account settings
+	SecurityPasswordReset      = "password.reset"
+	SecurityTwoFactorEnable    = "2fa.enable"
+	SecurityTwoFactorDisable   = "2fa.disable"
+	SecurityRecoveryCodesRenew = "2fa.recovery_codes"
+)
+
+type SecurityEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`            // Zero for failed logins with an unknown email
+	Email     string    `gorm:"column:email;index" json:"email"` // The email a login was attempted with
+	Type      string    `gorm:"column:type;not null" json:"type"`
+	Detail    string    `gorm:"column:detail" json:"detail,omitempty"`
+	IP        string    `gorm:"column:ip" json:"ip"`
+	UserAgent string    `gorm:"column:user_agent" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+type Session struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Family     string     `gorm:"column:family;size:255;uniqueIndex;not null" json:"-"` // Refresh token family of the login
+	UserAgent  string     `gorm:"column:user_agent" json:"user_agent"`
+	IP         string     `gorm:"column:ip" json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `gorm:"column:last_seen_at" json:"last_seen_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	Current    bool       `gorm:"-" json:"current"` // Set when listing, true for the caller's own session
+}
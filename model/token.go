@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Family    string     `gorm:"column:family;index;not null" json:"family"`               // Shared by every token rotated from the same login
+	TokenHash string     `gorm:"column:token_hash;size:255;uniqueIndex;not null" json:"-"` // SHA-256 of the token, the raw value is never stored
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	RotatedAt *time.Time `gorm:"column:rotated_at" json:"rotated_at"`
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	MFA       bool       `gorm:"column:mfa;not null;default:false" json:"mfa"` // The login passed two-factor authentication
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// ActiveToken is an issued token that has not been revoked or expired. The user and family
+// are indexed so every token of a user or login can be deleted at once.
+type ActiveToken struct {
+	TokenKey  string    `gorm:"column:token_key;primaryKey;size:64"` // TokenKey of the token, the raw value is never stored
+	UserID    uint      `gorm:"column:user_id;index;not null"`
+	Family    string    `gorm:"column:family;size:255;index"` // Refresh token family or session, empty for interim tokens
+	ExpiresAt time.Time `gorm:"column:expires_at;index;not null"`
+}
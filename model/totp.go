@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+type TwoFactor struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	Secret       string     `gorm:"column:secret;not null" json:"-"` // Base32 TOTP secret
+	Enabled      bool       `gorm:"column:enabled;default:false" json:"enabled"`
+	LastUsedStep int64      `gorm:"column:last_used_step;default:0" json:"-"` // Rejects replays of an accepted code
+	EnabledAt    *time.Time `gorm:"column:enabled_at" json:"enabled_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"column:code_hash;size:255;uniqueIndex;not null" json:"-"` // SHA-256 of the code
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TOTPDisableRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Code            string `json:"code" validate:"required"` // TOTP code or recovery code
+}
+
+type TwoFactorLoginRequest struct {
+	InterimToken string `json:"interim_token" validate:"required"`
+	Code         string `json:"code" validate:"required"` // TOTP code or recovery code
+}
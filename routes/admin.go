@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"backend/controller"
+	"backend/middleware"
+	"backend/model"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// AdminRoutes initializes the admin-only routes for the Fiber app
+func AdminRoutes(app *fiber.App, db *gorm.DB) {
+	admin := app.Group("/api/admin", middleware.AuthMiddleware(db), middleware.HeadersMiddleware())
+
+	admin.Get("/roles", controller.GetRoles(db), middleware.RequirePermission(model.PermissionRoleManage))
+	admin.Post("/users/:id/roles", controller.AssignRole(db), middleware.RequirePermission(model.PermissionRoleManage))
+	admin.Delete("/users/:id/roles/:role", controller.RemoveRole(db), middleware.RequirePermission(model.PermissionRoleManage))
+	admin.Post("/users/:id/logout", controller.ForceLogout(db), middleware.RequirePermission(model.PermissionSessionManage))
+	admin.Post("/users/:id/impersonate", controller.Impersonate(db), middleware.RequirePermission(model.PermissionImpersonate), middleware.DenyImpersonation())
+	admin.Get("/audit-logs", controller.GetAuditLogs(db), middleware.RequirePermission(model.PermissionAuditRead))
+	admin.Get("/service-accounts", controller.GetServiceAccounts(db), middleware.RequirePermission(model.PermissionAPIKeyManage))
+	admin.Post("/service-accounts", controller.CreateServiceAccount(db), middleware.RequirePermission(model.PermissionAPIKeyManage))
+	admin.Get("/service-accounts/:id/api-keys", controller.GetServiceAccountAPIKeys(db), middleware.RequirePermission(model.PermissionAPIKeyManage))
+	admin.Post("/service-accounts/:id/api-keys", controller.CreateServiceAccountAPIKey(db), middleware.RequirePermission(model.PermissionAPIKeyManage))
+	admin.Delete("/api-keys/:id", controller.RevokeAPIKey(db), middleware.RequirePermission(model.PermissionAPIKeyManage))
+}
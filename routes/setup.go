@@ -1,39 +1,86 @@
 package routes
 
 import (
+	"backend/config"
 	"backend/controller"
 	"backend/middleware"
+	"backend/model"
 
 	"github.com/gofiber/fiber/v3"
 	"gorm.io/gorm"
 )
 
 // SetupRoutes initializes the routes for the Fiber app
-func SetupRoutes(app *fiber.App, db *gorm.DB) {
+func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config) {
 
 	// Group routes for persons under /api/person
 	personGroup := app.Group("/api/person", middleware.HeadersMiddleware())
 	{
+		auth := middleware.AuthMiddleware(db)
+		sensitive := middleware.DenyImpersonation()
 
 		personGroup.Get("/verify", controller.VerifyEmail(db))
-		personGroup.Post("/", controller.CreatePerson(db))
-		personGroup.Get("/", controller.GetAllPersons(db))
-		personGroup.Get("/excel", controller.ExportPersons(db))
-		personGroup.Get("/:id", controller.GetPersonByID(db))
-		personGroup.Put("/:id", controller.UpdatePerson(db))
-		personGroup.Delete("/:id", controller.DeletePerson(db))
-
-		personGroup.Post("/register", controller.RegisterUser(db))
+		personGroup.Post("/verify/resend", controller.ResendVerification(db, cfg))
+		personGroup.Post("/", controller.CreatePerson(db), auth, middleware.RequirePermission(model.PermissionPersonCreate))
+		personGroup.Get("/", controller.GetAllPersons(db), auth, middleware.RequirePermission(model.PermissionPersonRead), middleware.RequireTwoFactor())
+		personGroup.Get("/excel", controller.ExportPersons(db), auth, middleware.RequirePermission(model.PermissionPersonExport), middleware.RequireTwoFactor())
+		personGroup.Get("/sessions", controller.GetSessions(db), auth)
+		personGroup.Get("/security-events", controller.GetSecurityEvents(db), auth)
+		personGroup.Delete("/sessions", controller.RevokeOtherSessions(db), auth, sensitive)
+		personGroup.Delete("/sessions/:id", controller.RevokeSession(db), auth, sensitive)
+		personGroup.Get("/session", controller.GetWebSession(), auth)
+		personGroup.Post("/session/logout", controller.LogoutWebSession(db), auth)
+		personGroup.Post("/email", controller.ChangeEmail(db, cfg), auth, sensitive)
+		personGroup.Post("/password", controller.ChangePassword(db), auth, sensitive)
+		personGroup.Get("/email/confirm", controller.ConfirmEmailChange(db))
+		personGroup.Get("/api-keys", controller.GetAPIKeys(db), auth)
+		personGroup.Post("/api-keys", controller.CreateAPIKey(db), auth, sensitive)
+		personGroup.Put("/api-keys/:id", controller.UpdateAPIKey(db), auth, sensitive)
+		personGroup.Delete("/api-keys/:id", controller.DeleteAPIKey(db), auth, sensitive)
+		personGroup.Get("/:id", controller.GetPersonByID(db), auth, middleware.RequirePermission(model.PermissionPersonRead), middleware.RequireTwoFactor())
+		personGroup.Put("/:id", controller.UpdatePerson(db), auth, middleware.RequirePermission(model.PermissionPersonUpdate))
+		personGroup.Delete("/:id", controller.DeletePerson(db), auth, middleware.RequirePermission(model.PermissionPersonDelete))
+
+		personGroup.Post("/register", controller.RegisterUser(db, cfg))
 		personGroup.Post("/login", controller.Login(db))
-		personGroup.Post("/logout", controller.Logout())
+		personGroup.Post("/login/2fa", controller.LoginTwoFactor(db))
+		personGroup.Post("/login/magic", controller.RequestMagicLink(db, cfg))
+		personGroup.Get("/login/magic/verify", controller.ConsumeMagicLink(db))
+		personGroup.Get("/oidc/:provider/login", controller.OIDCLogin(db))
+		personGroup.Get("/oidc/:provider/callback", controller.OIDCCallback(db))
+		personGroup.Post("/2fa/enroll", controller.EnrollTOTP(db, cfg), auth, sensitive)
+		personGroup.Post("/2fa/confirm", controller.ConfirmTOTP(db), auth, sensitive)
+		personGroup.Post("/2fa/disable", controller.DisableTOTP(db), auth, sensitive)
+		personGroup.Post("/2fa/recovery-codes", controller.RegenerateRecoveryCodes(db), auth, sensitive)
+		personGroup.Post("/forgot-password", controller.ForgotPassword(db, cfg))
+		personGroup.Post("/reset-password", controller.ResetPassword(db))
+		personGroup.Post("/refresh", controller.Refresh(db))
+		personGroup.Post("/logout", controller.Logout(db))
+	}
+
+	// Page the default password reset link opens
+	app.Get("/reset-password", controller.ResetPasswordPage())
+
+	// Liveness and readiness probes for the orchestrator
+	app.Get("/healthz", controller.Healthz())
+	app.Get("/readyz", controller.Readyz(db))
+
+	// Public signing keys for services verifying our tokens
+	app.Get("/.well-known/jwks.json", controller.JWKS())
+
+	// Token introspection and revocation for other services, authenticated by API key
+	oauthGroup := app.Group("/oauth", middleware.HeadersMiddleware(), middleware.ClientAuthMiddleware(db))
+	{
+		oauthGroup.Post("/introspect", controller.Introspect(db), middleware.RequirePermission(model.PermissionTokenIntrospect))
+		oauthGroup.Post("/revoke", controller.Revoke(db), middleware.RequirePermission(model.PermissionTokenRevoke))
 	}
 
 	// Group routes for branches under /api/branch
 	branchGroup := app.Group("/api/branch")
 	{
 		branchGroup.Get("/", controller.GetBranch(db))
-		branchGroup.Post("/", controller.CreateBranch(db))
-		branchGroup.Delete("/:id", controller.DeleteBranch(db))
+		branchGroup.Post("/", controller.CreateBranch(db), middleware.AuthMiddleware(db), middleware.RequirePermission(model.PermissionBranchCreate))
+		branchGroup.Delete("/:id", controller.DeleteBranch(db), middleware.AuthMiddleware(db), middleware.RequirePermission(model.PermissionBranchDelete))
 		branchGroup.Get("/info", controller.GetAllBranches(db))
 	}
 
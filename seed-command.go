@@ -0,0 +1,83 @@
+package main
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/migrations"
+	"backend/seed"
+	"backend/utils"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// countFlags collects repeated -count name=N flags
+type countFlags map[string]int
+
+func (c countFlags) String() string {
+	return fmt.Sprint(map[string]int(c))
+}
+
+func (c countFlags) Set(value string) error {
+	name, raw, found := strings.Cut(value, "=")
+	count, err := strconv.Atoi(raw)
+	if !found || name == "" || err != nil || count < 0 {
+		return fmt.Errorf("count %q must be name=N, for example users=100", value)
+	}
+	c[name] = count
+	return nil
+}
+
+// runSeed fills the database with the fixtures of a profile, counts given with -count
+// override the profile
+func runSeed(cfg *config.Config, args []string) error {
+	counts := countFlags{}
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	profile := flags.String("profile", "demo", "fixture profile, one of "+strings.Join(seed.ProfileNames(), ", "))
+	flags.Var(counts, "count", "records of a generator as name=N, repeatable, generators: "+strings.Join(seed.Names(), ", "))
+	seedValue := flags.Int64("seed", 1, "random seed, the same seed produces the same records")
+	batchSize := flags.Int("batch", 500, "records inserted per batch")
+	password := flags.String("password", "seed-password-1", "password of the seeded users")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	profileCounts, ok := seed.Profiles[*profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q, use one of %s", *profile, strings.Join(seed.ProfileNames(), ", "))
+	}
+	for name, count := range profileCounts {
+		if _, set := counts[name]; !set {
+			counts[name] = count
+		}
+	}
+
+	db := database.InitDB(cfg.Database)
+	if pending, err := migrations.Pending(db); err != nil {
+		return err
+	} else if pending > 0 {
+		return fmt.Errorf("%d pending migrations, run `backend migrate up` first", pending)
+	}
+	if err := database.SeedRoles(db, cfg.Auth.AdminEmail); err != nil {
+		return err
+	}
+	utils.SetArgon2Params(cfg.Auth.Argon2)
+
+	results, err := seed.Seed(db, seed.Options{
+		Seed:      *seedValue,
+		BatchSize: *batchSize,
+		Counts:    counts,
+		Password:  *password,
+	})
+	for _, result := range results {
+		fmt.Printf("%s: %d inserted, %d already present\n", result.Name, result.Inserted, result.Skipped)
+	}
+	if err != nil {
+		return err
+	}
+	if counts["users"] > 0 {
+		fmt.Printf("seeded users log in with the password %q\n", *password)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package seed
+
+import (
+	"backend/database"
+	"backend/model"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// branchDocument is the JSON stored in branches.branch_data
+type branchDocument struct {
+	BranchCode string         `json:"branch_code"`
+	BranchName string         `json:"branch_name"`
+	Location   model.Location `json:"location"`
+	Manager    model.Manager  `json:"manager"`
+	Opened     string         `json:"opened"`
+	Employees  int            `json:"employees"`
+}
+
+// cities anchor the branch locations
+var cities = []struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+}{
+	{"Lagos", 6.5244, 3.3792},
+	{"Nairobi", -1.2921, 36.8219},
+	{"Accra", 5.6037, -0.1870},
+	{"London", 51.5072, -0.1276},
+	{"Berlin", 52.5200, 13.4050},
+	{"Toronto", 43.6532, -79.3832},
+	{"Singapore", 1.3521, 103.8198},
+	{"Sao Paulo", -23.5558, -46.6396},
+}
+
+// streets are combined with a house number into branch addresses
+var streets = []string{"Market Street", "Harbour Road", "Station Avenue", "Church Lane", "Victoria Way", "Park Road"}
+
+// surnames are combined with firstNames into manager names
+var surnames = []string{"Okafor", "Mensah", "Smith", "Müller", "Tan", "Silva", "Kowalski", "Haddad"}
+
+// branches generates branches with a location and a manager
+type branches struct{}
+
+func (branches) Name() string {
+	return "branches"
+}
+
+func (branches) Generate(tx *gorm.DB, run *Run, from, to int) (int, error) {
+	records := make([]model.Branch, 0, to-from)
+	codes := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		r := run.Rand("branches", i)
+		city := cities[r.Intn(len(cities))]
+		document := branchDocument{
+			BranchCode: fmt.Sprintf("BR-%05d", i),
+			BranchName: fmt.Sprintf("%s Branch %d", city.Name, i),
+			Location: model.Location{
+				Address:   fmt.Sprintf("%d %s, %s", 1+r.Intn(200), pick(r, streets), city.Name),
+				Latitude:  math.Round((city.Latitude+r.Float64()*0.2-0.1)*1e6) / 1e6,
+				Longitude: math.Round((city.Longitude+r.Float64()*0.2-0.1)*1e6) / 1e6,
+			},
+			Manager: model.Manager{
+				Name:    pick(r, firstNames) + " " + pick(r, surnames),
+				Contact: fmt.Sprintf("+1555%07d", r.Intn(10_000_000)),
+			},
+			Opened:    time.Date(2000+r.Intn(25), time.Month(1+r.Intn(12)), 1+r.Intn(28), 0, 0, 0, 0, time.UTC).Format("2006-01-02"),
+			Employees: 5 + r.Intn(95),
+		}
+		data, err := json.Marshal(document)
+		if err != nil {
+			return 0, err
+		}
+		records = append(records, model.Branch{BranchData: datatypes.JSON(data)})
+		codes = append(codes, document.BranchCode)
+	}
+
+	var existing []string
+	code := database.JSONText(tx, "branch_data", "branch_code")
+	if err := tx.Model(&model.Branch{}).Where(code+" IN ?", codes).Pluck(code, &existing).Error; err != nil {
+		return 0, err
+	}
+	missing := withoutKeys(records, codes, existing)
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	if err := tx.Create(&missing).Error; err != nil {
+		return 0, err
+	}
+	return len(missing), nil
+}
@@ -0,0 +1,150 @@
+// Package seed fills the database with deterministic fixture data for demos and load tests.
+// Record i of a generator only depends on the seed and i, so re-running with the same seed
+// skips the records an earlier run inserted.
+package seed
+
+import (
+	"backend/utils"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// Generator creates the records of one model
+type Generator interface {
+	// Name is the key used in counts and profiles, such as users
+	Name() string
+	// Generate inserts the records from..to-1 that do not exist yet and returns how many it inserted
+	Generate(tx *gorm.DB, run *Run, from, to int) (int, error)
+}
+
+// Options control a seed run
+type Options struct {
+	Seed      int64          // Same seed, same records
+	BatchSize int            // Records inserted per statement and transaction
+	Counts    map[string]int // Records per generator, generators without a count are skipped
+	Password  string         // Password of every seeded user
+}
+
+// Result is what a run did for one generator
+type Result struct {
+	Name     string
+	Inserted int
+	Skipped  int // Already present from an earlier run
+}
+
+// Profiles are named record counts
+var Profiles = map[string]map[string]int{
+	"demo": {"users": 25, "branches": 10},
+	"load": {"users": 10000, "branches": 2000},
+}
+
+// generators run in this order, so later generators can use the records of earlier ones.
+// Add the generator of a new model here.
+var generators = []Generator{
+	users{},
+	branches{},
+}
+
+// Names lists the registered generators
+func Names() []string {
+	names := make([]string, 0, len(generators))
+	for _, generator := range generators {
+		names = append(names, generator.Name())
+	}
+	return names
+}
+
+// Run is the state shared by the generators of one seed run
+type Run struct {
+	Options
+	passwordHash string
+}
+
+// Rand returns the random source of record i of a generator
+func (r *Run) Rand(generator string, i int) *rand.Rand {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d/%s/%d", r.Seed, generator, i)
+	return rand.New(rand.NewSource(int64(hash.Sum64())))
+}
+
+// PasswordHash hashes the seed password once for all users of the run
+func (r *Run) PasswordHash() (string, error) {
+	if r.passwordHash == "" {
+		hash, err := utils.HashPassword(r.Password)
+		if err != nil {
+			return "", err
+		}
+		r.passwordHash = hash
+	}
+	return r.passwordHash, nil
+}
+
+// Seed runs every generator with a count, one transaction per batch
+func Seed(db *gorm.DB, opts Options) ([]Result, error) {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 500
+	}
+	for name := range opts.Counts {
+		if !known(name) {
+			return nil, fmt.Errorf("unknown generator %q, use one of %v", name, Names())
+		}
+	}
+
+	run := &Run{Options: opts}
+	var results []Result
+	for _, generator := range generators {
+		count := opts.Counts[generator.Name()]
+		if count <= 0 {
+			continue
+		}
+
+		result := Result{Name: generator.Name()}
+		for from := 0; from < count; from += opts.BatchSize {
+			to := min(from+opts.BatchSize, count)
+			var inserted int
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				inserted, err = generator.Generate(tx, run, from, to)
+				return err
+			})
+			if err != nil {
+				return results, fmt.Errorf("seeding %s: %w", generator.Name(), err)
+			}
+			result.Inserted += inserted
+			result.Skipped += to - from - inserted
+		}
+		log.Printf("seeded %s: %d inserted, %d already present", result.Name, result.Inserted, result.Skipped)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// known reports whether a generator is registered under name
+func known(name string) bool {
+	for _, generator := range generators {
+		if generator.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileNames lists the fixture profiles
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pick returns a random element of values
+func pick(r *rand.Rand, values []string) string {
+	return values[r.Intn(len(values))]
+}
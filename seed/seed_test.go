@@ -0,0 +1,114 @@
+package seed_test
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/migrations"
+	"backend/model"
+	"backend/seed"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB opens a migrated in-memory SQLite database with the roles, named after the test and suffix
+func newTestDB(t *testing.T, suffix string) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name()) + suffix
+	db, err := database.Connect(config.DatabaseConfig{
+		Driver:  database.DriverSQLite,
+		Name:    fmt.Sprintf("file:%s?mode=memory&cache=shared", name),
+		Connect: config.ConnectConfig{Attempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SeedRoles(db, ""); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// seeded is what a run generated, without the IDs and password hashes that differ between runs
+type seeded struct {
+	Users    []string
+	Branches []string
+}
+
+// snapshot returns the seeded records of db in insertion order
+func snapshot(t *testing.T, db *gorm.DB) seeded {
+	t.Helper()
+	var users []model.User
+	if err := db.Preload("AccountDetail").Preload("History").Order("id").Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	var branches []model.Branch
+	if err := db.Order("id").Find(&branches).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var result seeded
+	for _, user := range users {
+		result.Users = append(result.Users, fmt.Sprintf("%s %d %s %s %.2f %s",
+			user.Name, user.Age, user.Email, user.CreatedAt.UTC().Format(time.RFC3339Nano),
+			user.AccountDetail.Balance, user.History.Action))
+	}
+	for _, branch := range branches {
+		result.Branches = append(result.Branches, string(branch.BranchData))
+	}
+	return result
+}
+
+func TestSeedIsDeterministic(t *testing.T) {
+	opts := seed.Options{
+		Seed:      42,
+		BatchSize: 4,
+		Counts:    map[string]int{"users": 10, "branches": 10},
+		Password:  "seed-password-1",
+	}
+
+	first, second := newTestDB(t, "_first"), newTestDB(t, "_second")
+	if _, err := seed.Seed(first, opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seed.Seed(second, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := snapshot(t, first)
+	if got := snapshot(t, second); !reflect.DeepEqual(got, want) {
+		t.Fatalf("runs with the same seed differ:\n%v\n%v", got, want)
+	}
+
+	// A second run into the same database inserts nothing
+	results, err := seed.Seed(first, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Inserted != 0 || result.Skipped != opts.Counts[result.Name] {
+			t.Errorf("rerun of %s inserted %d and skipped %d", result.Name, result.Inserted, result.Skipped)
+		}
+	}
+
+	opts.Seed = 43
+	other := newTestDB(t, "_other")
+	if _, err := seed.Seed(other, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(t, other); reflect.DeepEqual(got, want) {
+		t.Fatal("runs with different seeds are identical")
+	}
+}
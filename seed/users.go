@@ -0,0 +1,106 @@
+package seed
+
+import (
+	"backend/model"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// firstNames are 4 to 8 letters, with the record number they fit the 8 to 12 character name rule
+var firstNames = []string{
+	"Adaeze", "Alan", "Amara", "Bianca", "Carlos", "Chen", "Dmitri", "Elena", "Farah", "Grace",
+	"Hiro", "Ingrid", "Jamal", "Kofi", "Leila", "Marco", "Nadia", "Oskar", "Priya", "Quinn",
+	"Rafael", "Sofia", "Tariq", "Ursula", "Viktor", "Wanjiru", "Xavier", "Yusuf", "Zara", "Liam",
+}
+
+// seedEpoch is the reference time of seeded creation dates, so they do not depend on when the seed runs
+var seedEpoch = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// historyActions are the history entries of seeded accounts
+var historyActions = []string{
+	"account successfully created",
+	"Seeded account with opening balance",
+	"Imported from fixture data",
+}
+
+// users generates verified users with an account detail, a history entry and the user role
+type users struct{}
+
+func (users) Name() string {
+	return "users"
+}
+
+func (users) Generate(tx *gorm.DB, run *Run, from, to int) (int, error) {
+	passwordHash, err := run.PasswordHash()
+	if err != nil {
+		return 0, err
+	}
+	var role model.Role
+	if err := tx.Where("name = ?", model.RoleUser).First(&role).Error; err != nil {
+		return 0, fmt.Errorf("user role missing, seed the roles first: %w", err)
+	}
+
+	records := make([]model.User, 0, to-from)
+	emails := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		r := run.Rand("users", i)
+		first := pick(r, firstNames)
+		email := fmt.Sprintf("%s.%d@seed.example.com", strings.ToLower(first), i)
+		records = append(records, model.User{
+			Name:       userName(first, i),
+			Age:        18 + r.Intn(48),
+			Email:      email,
+			Password:   passwordHash,
+			IsVerified: true,
+			AccountDetail: model.AccountDetail{
+				Balance: math.Round(r.Float64()*1_000_000) / 100,
+			},
+			History: model.History{
+				Action: pick(r, historyActions),
+			},
+			Roles:     []model.Role{role},
+			CreatedAt: seedEpoch.Add(-time.Duration(r.Intn(365*24)) * time.Hour),
+		})
+		emails = append(emails, email)
+	}
+
+	var existing []string
+	if err := tx.Model(&model.User{}).Where("email IN ?", emails).Pluck("email", &existing).Error; err != nil {
+		return 0, err
+	}
+	missing := withoutKeys(records, emails, existing)
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	if err := tx.Create(&missing).Error; err != nil {
+		return 0, err
+	}
+	return len(missing), nil
+}
+
+// userName appends i to the first name, at least 4 digits, and shortens the first name when
+// needed to stay within the 12 character name rule
+func userName(first string, i int) string {
+	suffix := fmt.Sprintf("%04d", i)
+	return first[:min(len(first), max(12-len(suffix), 0))] + suffix
+}
+
+// withoutKeys drops the records whose key, at the same position in keys, is in existing
+func withoutKeys[T any](records []T, keys []string, existing []string) []T {
+	skip := make(map[string]bool, len(existing))
+	for _, key := range existing {
+		skip[key] = true
+	}
+
+	var missing []T
+	for i, record := range records {
+		if !skip[keys[i]] {
+			missing = append(missing, record)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,29 @@
+package seed
+
+import "testing"
+
+func TestUserName(t *testing.T) {
+	tests := []struct {
+		first string
+		i     int
+		want  string
+	}{
+		{first: "Alan", i: 7, want: "Alan0007"},
+		{first: "Wanjiru", i: 9999, want: "Wanjiru9999"},
+		{first: "Wanjiru", i: 10000, want: "Wanjiru10000"},
+		{first: "Wanjiru", i: 19999, want: "Wanjiru19999"},
+		{first: "Adaeze", i: 1234567, want: "Adaez1234567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := userName(tt.first, tt.i)
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			if len(got) < 8 || len(got) > 12 {
+				t.Fatalf("name %q breaks the 8 to 12 character rule", got)
+			}
+		})
+	}
+}
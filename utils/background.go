@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// background tracks the work started by RunInBackground
+var background sync.WaitGroup
+
+// RunInBackground runs fn in its own goroutine, for work such as emails that should not hold up
+// a response. Shutdown waits for it with WaitBackground.
+func RunInBackground(fn func()) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		fn()
+	}()
+}
+
+// WaitBackground waits for the work started by RunInBackground to finish, or for ctx to expire
+func WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
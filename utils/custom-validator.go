@@ -15,8 +15,6 @@ type CustomValidator struct {
 
 // Validate performs the validation and returns an error if validation fails
 func (cv *CustomValidator) Validate(obj any) error {
-	fmt.Printf("Input data: %+v\n", obj)
-
 	if err := cv.validator.Struct(obj); err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
 			return fiber.NewError(fiber.StatusBadRequest, cv.formatValidationErrors(validationErrors))
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"backend/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DatabaseTokenStore keeps active tokens in the active_tokens table so they survive restarts
+// and are shared between instances
+type DatabaseTokenStore struct {
+	db *gorm.DB
+}
+
+// NewDatabaseTokenStore returns the store kept in the migrated active_tokens table
+func NewDatabaseTokenStore(db *gorm.DB) *DatabaseTokenStore {
+	return &DatabaseTokenStore{db: db}
+}
+
+// Store saves the token info under the given key
+func (s *DatabaseTokenStore) Store(key string, info TokenInfo) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "family", "expires_at"}),
+	}).Create(&model.ActiveToken{
+		TokenKey:  key,
+		UserID:    info.UserID,
+		Family:    info.Family,
+		ExpiresAt: info.Expiration,
+	}).Error
+}
+
+// Load returns the token info for the given key, expired tokens are not found
+func (s *DatabaseTokenStore) Load(key string) (TokenInfo, error) {
+	// Find rather than First, a missing key is common and not worth logging
+	var token model.ActiveToken
+	result := s.db.Where("token_key = ? AND expires_at > ?", key, time.Now()).Limit(1).Find(&token)
+	if result.Error != nil {
+		return TokenInfo{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return TokenInfo{}, ErrTokenNotFound
+	}
+	return TokenInfo{UserID: token.UserID, Family: token.Family, Expiration: token.ExpiresAt}, nil
+}
+
+// Delete removes the given key, returning ErrTokenNotFound if it was absent
+func (s *DatabaseTokenStore) Delete(key string) error {
+	result := s.db.Where("token_key = ?", key).Delete(&model.ActiveToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// DeleteUser removes every token of the user
+func (s *DatabaseTokenStore) DeleteUser(userID uint) (int, error) {
+	result := s.db.Where("user_id = ?", userID).Delete(&model.ActiveToken{})
+	return int(result.RowsAffected), result.Error
+}
+
+// DeleteFamily removes every token issued for the refresh token family or session
+func (s *DatabaseTokenStore) DeleteFamily(family string) (int, error) {
+	if family == "" {
+		return 0, nil
+	}
+	result := s.db.Where("family = ?", family).Delete(&model.ActiveToken{})
+	return int(result.RowsAffected), result.Error
+}
+
+// PurgeExpired removes every token that expired before now
+func (s *DatabaseTokenStore) PurgeExpired(now time.Time) (int, error) {
+	result := s.db.Where("expires_at <= ?", now).Delete(&model.ActiveToken{})
+	return int(result.RowsAffected), result.Error
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"backend/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// emailChangeTTL is how long the link sent to the new address stays valid
+const emailChangeTTL = 24 * time.Hour
+
+// Define custom error messages
+var (
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+	ErrEmailTaken              = errors.New("email already exists")
+)
+
+// emailTaken reports whether another user already has the email
+func emailTaken(db *gorm.DB, email string, userID uint) (bool, error) {
+	var count int64
+	if err := db.Model(&model.User{}).Where("email = ? AND id <> ?", email, userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateEmailChange replaces the user's pending email changes with one to newEmail and returns
+// the token to send to the new address
+func CreateEmailChange(db *gorm.DB, userID uint, newEmail string) (string, error) {
+	taken, err := emailTaken(db, newEmail, userID)
+	if err != nil {
+		return "", err
+	}
+	if taken {
+		return "", ErrEmailTaken
+	}
+
+	token := GenerateVerificationToken()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", userID).Delete(&model.EmailChange{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.EmailChange{
+			UserID:    userID,
+			NewEmail:  newEmail,
+			TokenHash: TokenKey(token),
+			ExpiresAt: time.Now().Add(emailChangeTTL),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConfirmEmailChange consumes the token and switches the user to the new, now verified, address.
+// It returns the change and the address it replaced.
+func ConfirmEmailChange(db *gorm.DB, token string) (*model.EmailChange, string, error) {
+	var change model.EmailChange
+	if err := db.Where("token_hash = ?", TokenKey(token)).First(&change).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrInvalidEmailChangeToken
+		}
+		return nil, "", err
+	}
+	if change.UsedAt != nil || change.ExpiresAt.Before(time.Now()) {
+		return nil, "", ErrInvalidEmailChangeToken
+	}
+
+	var user model.User
+	if err := db.First(&user, change.UserID).Error; err != nil {
+		return nil, "", err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Losing a concurrent race means the token was already consumed
+		result := tx.Model(&model.EmailChange{}).
+			Where("id = ? AND used_at IS NULL", change.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidEmailChangeToken
+		}
+
+		// Someone may have registered the address since the change was requested
+		taken, err := emailTaken(tx, change.NewEmail, change.UserID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrEmailTaken
+		}
+
+		return tx.Model(&model.User{}).Where("id = ?", change.UserID).Updates(map[string]interface{}{
+			"email":       change.NewEmail,
+			"is_verified": true,
+		}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &change, user.Email, nil
+}
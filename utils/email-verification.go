@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"backend/config"
+	"backend/model"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidVerificationToken is returned for unknown, used or expired verification tokens
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// EmailVerificationConfig controls how email addresses are verified
+type EmailVerificationConfig struct {
+	Required         bool          // Whether unverified accounts are refused at login
+	TokenTTL         time.Duration // How long an emailed verification link stays valid
+	ResendInterval   time.Duration // Minimum time between two verification emails to the same account
+	UnverifiedMaxAge time.Duration // Unverified accounts older than this are deleted, zero keeps them
+	CleanupInterval  time.Duration // How often unverified accounts are looked for
+}
+
+// emailVerification is the active configuration, see SetEmailVerificationConfig
+var emailVerification = EmailVerificationConfig{
+	Required:         true,
+	TokenTTL:         24 * time.Hour,
+	ResendInterval:   5 * time.Minute,
+	UnverifiedMaxAge: 7 * 24 * time.Hour,
+	CleanupInterval:  time.Hour,
+}
+
+// SetEmailVerificationConfig replaces the defaults with the validated configuration
+func SetEmailVerificationConfig(cfg config.EmailVerificationConfig) {
+	emailVerification = EmailVerificationConfig{
+		Required:         cfg.Required,
+		TokenTTL:         cfg.TokenTTL,
+		ResendInterval:   cfg.ResendInterval,
+		UnverifiedMaxAge: time.Duration(cfg.UnverifiedAccountDays) * 24 * time.Hour,
+		CleanupInterval:  cfg.CleanupInterval,
+	}
+}
+
+// EmailVerificationRequired reports whether unverified accounts are refused at login
+func EmailVerificationRequired() bool {
+	return emailVerification.Required
+}
+
+// CreateEmailVerification replaces the user's outstanding verification tokens with a new one
+// and returns it, only its hash is stored
+func CreateEmailVerification(db *gorm.DB, userID uint) (string, error) {
+	token := GenerateVerificationToken()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", userID).Delete(&model.EmailVerification{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.EmailVerification{
+			UserID:    userID,
+			TokenHash: TokenKey(token),
+			ExpiresAt: time.Now().Add(emailVerification.TokenTTL),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// VerificationResendWait returns how long the user must wait before another verification
+// email is sent, zero if one can be sent now
+func VerificationResendWait(db *gorm.DB, userID uint) (time.Duration, error) {
+	var latest model.EmailVerification
+	err := db.Where("user_id = ?", userID).Order("created_at DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return max(0, time.Until(latest.CreatedAt.Add(emailVerification.ResendInterval))), nil
+}
+
+// ConsumeEmailVerification marks the token as used and the user as verified
+func ConsumeEmailVerification(db *gorm.DB, token string) (uint, error) {
+	var verification model.EmailVerification
+	if err := db.Where("token_hash = ?", TokenKey(token)).First(&verification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrInvalidVerificationToken
+		}
+		return 0, err
+	}
+	if verification.UsedAt != nil || verification.ExpiresAt.Before(time.Now()) {
+		return 0, ErrInvalidVerificationToken
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Losing a concurrent race means the token was already consumed
+		result := tx.Model(&model.EmailVerification{}).
+			Where("id = ? AND used_at IS NULL", verification.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidVerificationToken
+		}
+		return tx.Model(&model.User{}).Where("id = ?", verification.UserID).Update("is_verified", true).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return verification.UserID, nil
+}
+
+// CleanupUnverifiedAccounts deletes accounts that were never verified and are older than maxAge
+func CleanupUnverifiedAccounts(db *gorm.DB, maxAge time.Duration) (int, error) {
+	var users []model.User
+	if err := db.Where("is_verified = ? AND created_at < ?", false, time.Now().Add(-maxAge)).Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, user := range users {
+		if err := DeleteUser(db, &user); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// DeleteUser removes a user together with the rows that belong to it and ends every session of
+// the user, all in one transaction
+func DeleteUser(db *gorm.DB, user *model.User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, owned := range []interface{}{
+			&model.EmailVerification{},
+			&model.PasswordReset{},
+			&model.PasswordHistory{},
+			&model.EmailChange{},
+			&model.MagicLink{},
+			&model.RefreshToken{},
+			&model.Session{},
+			&model.RecoveryCode{},
+			&model.TwoFactor{},
+			&model.APIKey{},
+			&model.UserIdentity{},
+			&model.SecurityEvent{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(owned).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("user_id = ? OR actor_id = ?", user.ID, user.ID).Delete(&model.AuditLog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("throttle_key = ?", accountThrottleKey(user.Email)).Delete(&model.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(user).Association("Roles").Clear(); err != nil {
+			return err
+		}
+		// Also removes the account details and history
+		if err := tx.Select(clause.Associations).Delete(user).Error; err != nil {
+			return err
+		}
+		_, err := tokenStoreIn(tx).DeleteUser(user.ID)
+		return err
+	})
+}
+
+// RunUnverifiedCleanup periodically deletes stale unverified accounts until ctx is cancelled.
+// It returns immediately when the cleanup is disabled, or when verification is not required
+// since unverified accounts can then log in and be in use.
+func RunUnverifiedCleanup(ctx context.Context, db *gorm.DB) {
+	if emailVerification.UnverifiedMaxAge == 0 || !emailVerification.Required {
+		return
+	}
+
+	ticker := time.NewTicker(emailVerification.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := CleanupUnverifiedAccounts(db.WithContext(ctx), emailVerification.UnverifiedMaxAge)
+		if err != nil {
+			log.Printf("Could not clean up unverified accounts: %v", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("%d unverified accounts have been removed.", deleted)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package utils_test
+
+import (
+	"backend/config"
+	"backend/model"
+	"backend/utils"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCleanupUnverifiedAccountsDeletesOwnedRows(t *testing.T) {
+	db := newTestDB(t)
+	stale := newTestUser(t, db, "stale@example.com")
+	kept := newTestUser(t, db, "kept@example.com")
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := db.Model(stale).Updates(map[string]interface{}{"is_verified": false, "created_at": old}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	owned := []interface{}{
+		&model.EmailVerification{UserID: stale.ID, TokenHash: "verification", ExpiresAt: old},
+		&model.PasswordReset{UserID: stale.ID, TokenHash: "reset", ExpiresAt: old},
+		&model.PasswordHistory{UserID: stale.ID, Hash: "hash"},
+		&model.EmailChange{UserID: stale.ID, NewEmail: "new@example.com", TokenHash: "change", ExpiresAt: old},
+		&model.MagicLink{UserID: stale.ID, TokenID: "link", BrowserHash: "browser", ExpiresAt: old},
+		&model.RefreshToken{UserID: stale.ID, Family: "family", TokenHash: "refresh", ExpiresAt: old},
+		&model.Session{UserID: stale.ID, Family: "family"},
+		&model.RecoveryCode{UserID: stale.ID, CodeHash: "code"},
+		&model.TwoFactor{UserID: stale.ID, Secret: "secret"},
+		&model.APIKey{UserID: &stale.ID, Name: "key", Prefix: "prefix", KeyHash: "key"},
+		&model.UserIdentity{UserID: stale.ID, Provider: "mock", Subject: "subject"},
+		&model.SecurityEvent{UserID: stale.ID, Email: stale.Email, Type: model.SecurityLoginFailure},
+		&model.AuditLog{ActorID: kept.ID, UserID: stale.ID, Action: model.AuditImpersonationStart},
+		&model.LoginThrottle{Key: "account:" + stale.Email, Failures: 1},
+	}
+	for _, row := range owned {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := utils.CleanupUnverifiedAccounts(db, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("deleted %d accounts, want 1", deleted)
+	}
+
+	for _, row := range append(owned, &model.User{}, &model.AccountDetail{}, &model.History{}) {
+		var count int64
+		if err := db.Model(row).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, isUser := row.(*model.User); isUser {
+			count-- // The verified user is kept
+		}
+		if count != 0 {
+			t.Errorf("%T has %d rows left", row, count)
+		}
+	}
+
+	var assignments int64
+	if err := db.Table("user_roles").Where("user_id = ?", stale.ID).Count(&assignments).Error; err != nil {
+		t.Fatal(err)
+	}
+	if assignments != 0 {
+		t.Errorf("%d role assignments left", assignments)
+	}
+}
+
+func TestRunUnverifiedCleanupDisabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		required bool
+		days     int
+	}{
+		{name: "verification not required", required: false, days: 7},
+		{name: "no maximum age", required: true, days: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utils.SetEmailVerificationConfig(config.EmailVerificationConfig{
+				Required:              tt.required,
+				UnverifiedAccountDays: tt.days,
+				CleanupInterval:       time.Millisecond,
+			})
+			t.Cleanup(func() { utils.SetEmailVerificationConfig(config.Default(config.ProfileTest).Auth.EmailVerification) })
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				utils.RunUnverifiedCleanup(context.Background(), nil)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("cleanup is running")
+			}
+		})
+	}
+}
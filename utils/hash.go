@@ -1,14 +1,111 @@
 package utils
 
 import (
+	"backend/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword hashes a password using bcrypt with a default cost.
+// ErrInvalidPasswordHash is returned for stored hashes in an unknown format
+var ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+// Argon2Params are the argon2id cost parameters new hashes are created with
+type Argon2Params struct {
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// argon2Params is the active configuration, see SetArgon2Params
+var argon2Params = Argon2Params{
+	Memory:  64 * 1024,
+	Time:    3,
+	Threads: 2,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// SetArgon2Params replaces the default cost of new hashes with the validated configuration
+func SetArgon2Params(cfg config.Argon2Config) {
+	argon2Params.Memory = cfg.Memory
+	argon2Params.Time = cfg.Time
+	argon2Params.Threads = cfg.Threads
+}
+
+// HashPassword hashes a password with argon2id, encoded as $argon2id$v=19$m=..,t=..,p=..$salt$hash
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
+	salt := make([]byte, argon2Params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return string(hashedPassword), nil
+
+	key := argon2.IDKey([]byte(password), salt, argon2Params.Time, argon2Params.Memory, argon2Params.Threads, argon2Params.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Params.Memory, argon2Params.Time, argon2Params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword checks a password against an argon2id or legacy bcrypt hash. needsRehash is set
+// when the password matches but the hash is bcrypt or uses outdated argon2id parameters.
+func VerifyPassword(hash string, password string) (ok bool, needsRehash bool, err error) {
+	if strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$") {
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		return true, true, nil
+	}
+
+	params, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false, false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, false, nil
+	}
+
+	outdated := params.Memory != argon2Params.Memory || params.Time != argon2Params.Time ||
+		params.Threads != argon2Params.Threads || uint32(len(key)) != argon2Params.KeyLen
+	return true, outdated, nil
+}
+
+// decodeArgon2Hash splits an encoded argon2id hash into its parameters, salt and key
+func decodeArgon2Hash(hash string) (*Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	params := &Argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	return params, salt, key, nil
 }
@@ -0,0 +1,59 @@
+package utils_test
+
+import (
+	"backend/config"
+	"backend/utils"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyPasswordRehash(t *testing.T) {
+	t.Cleanup(func() { utils.SetArgon2Params(config.Default(config.ProfileTest).Auth.Argon2) })
+
+	cheap := config.Argon2Config{Memory: 8 * 1024, Time: 1, Threads: 1}
+	current := config.Argon2Config{Memory: 16 * 1024, Time: 1, Threads: 1}
+
+	utils.SetArgon2Params(cheap)
+	outdated, err := utils.HashPassword("Correct-horse-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.SetArgon2Params(current)
+	upToDate, err := utils.HashPassword("Correct-horse-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := bcrypt.GenerateFromPassword([]byte("Correct-horse-1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		hash       string
+		password   string
+		wantOK     bool
+		wantRehash bool
+		wantErr    bool
+	}{
+		{name: "current argon2id", hash: upToDate, password: "Correct-horse-1", wantOK: true},
+		{name: "outdated argon2id", hash: outdated, password: "Correct-horse-1", wantOK: true, wantRehash: true},
+		{name: "legacy bcrypt", hash: string(legacy), password: "Correct-horse-1", wantOK: true, wantRehash: true},
+		{name: "wrong password for argon2id", hash: outdated, password: "wrong"},
+		{name: "wrong password for bcrypt", hash: string(legacy), password: "wrong"},
+		{name: "unknown format", hash: "plaintext", password: "plaintext", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash, err := utils.VerifyPassword(tt.hash, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || needsRehash != tt.wantRehash {
+				t.Fatalf("got ok %v rehash %v, want ok %v rehash %v", ok, needsRehash, tt.wantOK, tt.wantRehash)
+			}
+		})
+	}
+}
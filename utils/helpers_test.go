@@ -0,0 +1,58 @@
+package utils_test
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/migrations"
+	"backend/model"
+	"backend/utils"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB opens a migrated in-memory SQLite database private to the test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := database.Connect(config.DatabaseConfig{
+		Driver:  database.DriverSQLite,
+		Name:    fmt.Sprintf("file:%s?mode=memory&cache=shared", name),
+		Connect: config.ConnectConfig{Attempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SeedRoles(db, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.LoadSigningKeys(config.JWTConfig{Secret: "test-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestUser creates a verified user with the default role
+func newTestUser(t *testing.T, db *gorm.DB, email string) *model.User {
+	t.Helper()
+	var role model.Role
+	if err := db.Where("name = ?", model.RoleUser).First(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{Name: "testuser", Age: 30, Email: email, Password: "unused", IsVerified: true, Roles: []model.Role{role}}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"backend/model"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+// ActorClaim names the admin acting on behalf of the token's user (RFC 8693)
+const ActorClaim = "act"
+
+// ErrCannotImpersonate is returned when the target may not be impersonated
+var ErrCannotImpersonate = errors.New("this user cannot be impersonated")
+
+// IssueImpersonationToken issues an access token for the target user that names the admin in
+// the act claim. It has the target's roles, cannot be refreshed and expires with AccessTokenTTL.
+func IssueImpersonationToken(db *gorm.DB, actorID uint, targetID uint) (string, error) {
+	if actorID == targetID {
+		return "", ErrCannotImpersonate
+	}
+
+	// The admin never passed the target's second factor
+	claims, err := UserClaims(db, targetID, false)
+	if err != nil {
+		return "", err
+	}
+
+	// Impersonating another admin would lend their privileges
+	if slices.Contains(stringsClaim(claims, "permissions"), model.PermissionImpersonate) {
+		return "", ErrCannotImpersonate
+	}
+
+	claims[ActorClaim] = jwt.MapClaims{"sub": strconv.FormatUint(uint64(actorID), 10)}
+	return generateAccessToken(targetID, "", claims)
+}
+
+// RecordAudit writes an entry to the audit log, failures are only logged
+func RecordAudit(db *gorm.DB, entry model.AuditLog) {
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Could not write audit log entry %s for user %d: %v", entry.Action, entry.UserID, err)
+	}
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"backend/model"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Token type hints of RFC 7662 and RFC 7009
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// Introspection is the RFC 7662 response describing a token, only Active is set for
+// unknown, expired or revoked tokens
+type Introspection struct {
+	Active    bool              `json:"active"`
+	Scope     string            `json:"scope,omitempty"` // Space-separated permissions
+	TokenType string            `json:"token_type,omitempty"`
+	Subject   string            `json:"sub,omitempty"` // User ID
+	Username  string            `json:"username,omitempty"`
+	ExpiresAt int64             `json:"exp,omitempty"`
+	IssuedAt  int64             `json:"iat,omitempty"`
+	JTI       string            `json:"jti,omitempty"`
+	Actor     map[string]string `json:"act,omitempty"` // Set for impersonation tokens
+}
+
+// IntrospectToken describes an access or refresh token, trying the hinted type first
+func IntrospectToken(db *gorm.DB, token string, hint string) (*Introspection, error) {
+	lookups := []func(*gorm.DB, string) (*Introspection, error){introspectAccessToken, introspectRefreshToken}
+	if hint == TokenTypeRefresh {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		introspection, err := lookup(db, token)
+		if err != nil {
+			return nil, err
+		}
+		if introspection.Active {
+			return introspection, nil
+		}
+	}
+	return &Introspection{Active: false}, nil
+}
+
+// introspectAccessToken describes an active access token from its claims
+func introspectAccessToken(db *gorm.DB, token string) (*Introspection, error) {
+	claims, err := ValidateToken(token)
+	if err != nil {
+		return &Introspection{Active: false}, nil
+	}
+
+	principal, err := PrincipalFromClaims(claims)
+	if err != nil {
+		return &Introspection{Active: false}, nil
+	}
+
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+	jti, _ := claims["jti"].(string)
+	introspection := &Introspection{
+		Active:    true,
+		Scope:     strings.Join(principal.Permissions, " "),
+		TokenType: TokenTypeAccess,
+		Subject:   strconv.FormatUint(uint64(principal.UserID), 10),
+		ExpiresAt: int64(exp),
+		IssuedAt:  int64(iat),
+		JTI:       jti,
+	}
+	if principal.Impersonated() {
+		introspection.Actor = map[string]string{"sub": strconv.FormatUint(uint64(principal.ActorID), 10)}
+	}
+	return withUsername(db, introspection, principal.UserID)
+}
+
+// introspectRefreshToken describes a refresh token that was neither rotated nor revoked
+func introspectRefreshToken(db *gorm.DB, token string) (*Introspection, error) {
+	var record model.RefreshToken
+	if err := db.Where("token_hash = ?", TokenKey(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+	if record.RevokedAt != nil || record.RotatedAt != nil || record.ExpiresAt.Before(time.Now()) {
+		return &Introspection{Active: false}, nil
+	}
+
+	// Refresh tokens are exchanged for the user's current permissions
+	claims, err := UserClaims(db, record.UserID, record.MFA)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	introspection := &Introspection{
+		Active:    true,
+		Scope:     strings.Join(stringsClaim(claims, "permissions"), " "),
+		TokenType: TokenTypeRefresh,
+		Subject:   strconv.FormatUint(uint64(record.UserID), 10),
+		ExpiresAt: record.ExpiresAt.Unix(),
+		IssuedAt:  record.CreatedAt.Unix(),
+	}
+	return withUsername(db, introspection, record.UserID)
+}
+
+// withUsername adds the email of the token's user, a deleted user makes the token inactive
+func withUsername(db *gorm.DB, introspection *Introspection, userID uint) (*Introspection, error) {
+	var user model.User
+	if err := db.Select("id", "email").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+	introspection.Username = user.Email
+	return introspection, nil
+}
+
+// RevokeToken ends an access or refresh token. Revoking a refresh token ends its whole family,
+// including the access tokens issued from it. Unknown tokens are ignored as RFC 7009 requires.
+func RevokeToken(db *gorm.DB, token string) error {
+	if err := tokenStore.Delete(TokenKey(token)); err == nil {
+		return nil
+	}
+
+	var record model.RefreshToken
+	if err := db.Where("token_hash = ?", TokenKey(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return RevokeTokenFamily(db, record.Family)
+}
@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"backend/config"
+	"backend/model"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleConfig controls how failed logins are delayed and locked out
+type LoginThrottleConfig struct {
+	MaxAccountFailures int           // Failures before an account is locked
+	MaxIPFailures      int           // Failures before a client IP is locked
+	LockoutDuration    time.Duration // How long a lockout lasts, also the window failures are counted in
+	BaseDelay          time.Duration // Delay after the first failure, doubled after each further one
+	MaxDelay           time.Duration // Upper bound for the progressive delay
+}
+
+// loginThrottle is the active configuration, see SetLoginThrottleConfig
+var loginThrottle = LoginThrottleConfig{
+	MaxAccountFailures: 5,
+	MaxIPFailures:      20,
+	LockoutDuration:    15 * time.Minute,
+	BaseDelay:          time.Second,
+	MaxDelay:           30 * time.Second,
+}
+
+// SetLoginThrottleConfig replaces the defaults with the validated configuration
+func SetLoginThrottleConfig(cfg config.LoginThrottleConfig) {
+	loginThrottle = LoginThrottleConfig{
+		MaxAccountFailures: cfg.MaxAccountFailures,
+		MaxIPFailures:      cfg.MaxIPFailures,
+		LockoutDuration:    cfg.LockoutDuration,
+		BaseDelay:          cfg.BaseDelay,
+		MaxDelay:           cfg.MaxDelay,
+	}
+}
+
+// accountThrottlePrefix starts the key of an account's counter, followed by its email
+const accountThrottlePrefix = "account:"
+
+// accountThrottleKey and ipThrottleKey name the counters of a login attempt
+func accountThrottleKey(email string) string {
+	return accountThrottlePrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// resetThrottlePrefix keeps password reset requests on their own counters, so they never
+// lock the account's logins
+const resetThrottlePrefix = "reset:"
+
+// passwordConfirmThrottleKey names the counter of current-password checks by a signed-in
+// user, kept apart from the account's logins
+func passwordConfirmThrottleKey(userID uint) string {
+	return "confirm:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// twoFactorConfirmThrottleKey names the counter of two-factor code checks by a signed-in
+// user, apart from their password checks so a known password cannot reset it
+func twoFactorConfirmThrottleKey(userID uint) string {
+	return "confirm-2fa:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// CheckLoginThrottle returns how long the caller must wait before another login attempt
+// for the email from the IP is allowed, zero if it is allowed now
+func CheckLoginThrottle(db *gorm.DB, email string, ip string) (time.Duration, error) {
+	return checkThrottle(db, accountThrottleKey(email), ipThrottleKey(ip))
+}
+
+// CheckPasswordResetThrottle returns how long the caller must wait before another password
+// reset email to the address or from the IP is allowed, zero if it is allowed now
+func CheckPasswordResetThrottle(db *gorm.DB, email string, ip string) (time.Duration, error) {
+	return checkThrottle(db, resetThrottlePrefix+accountThrottleKey(email), resetThrottlePrefix+ipThrottleKey(ip))
+}
+
+// RecordPasswordResetRequest counts a password reset request for the address and the IP,
+// whether or not the address is registered. It reports whether either is now locked.
+func RecordPasswordResetRequest(db *gorm.DB, email string, ip string) (bool, error) {
+	accountLocked, err := recordFailure(db, resetThrottlePrefix+accountThrottleKey(email), loginThrottle.MaxAccountFailures)
+	if err != nil {
+		return false, err
+	}
+	ipLocked, err := recordFailure(db, resetThrottlePrefix+ipThrottleKey(ip), loginThrottle.MaxIPFailures)
+	if err != nil {
+		return false, err
+	}
+	return accountLocked || ipLocked, nil
+}
+
+// checkThrottle returns the longest wait imposed by the counters with the given keys
+func checkThrottle(db *gorm.DB, keys ...string) (time.Duration, error) {
+	var throttles []model.LoginThrottle
+	if err := db.Where("throttle_key IN ?", keys).Find(&throttles).Error; err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			wait = max(wait, throttle.LockedUntil.Sub(now))
+			continue
+		}
+		if throttle.Failures > 0 && now.Sub(throttle.LastFailureAt) < loginThrottle.LockoutDuration {
+			wait = max(wait, throttle.LastFailureAt.Add(progressiveDelay(throttle.Failures)).Sub(now))
+		}
+	}
+	return wait, nil
+}
+
+// CheckPasswordConfirmThrottle returns how long the user must wait before their current
+// password is checked again, zero if it can be checked now
+func CheckPasswordConfirmThrottle(db *gorm.DB, userID uint) (time.Duration, error) {
+	return checkThrottle(db, passwordConfirmThrottleKey(userID))
+}
+
+// RecordPasswordConfirmFailure counts a wrong current password and reports whether the
+// user's checks are now locked
+func RecordPasswordConfirmFailure(db *gorm.DB, userID uint) (bool, error) {
+	return recordFailure(db, passwordConfirmThrottleKey(userID), loginThrottle.MaxAccountFailures)
+}
+
+// ResetPasswordConfirmFailures clears the user's counter after their password was confirmed
+func ResetPasswordConfirmFailures(db *gorm.DB, userID uint) error {
+	return db.Where("throttle_key = ?", passwordConfirmThrottleKey(userID)).Delete(&model.LoginThrottle{}).Error
+}
+
+// CheckTwoFactorConfirmThrottle returns how long the user must wait before another of their
+// two-factor codes is checked, zero if it can be checked now
+func CheckTwoFactorConfirmThrottle(db *gorm.DB, userID uint) (time.Duration, error) {
+	return checkThrottle(db, twoFactorConfirmThrottleKey(userID))
+}
+
+// RecordTwoFactorConfirmFailure counts a wrong two-factor code and reports whether the user's
+// checks are now locked
+func RecordTwoFactorConfirmFailure(db *gorm.DB, userID uint) (bool, error) {
+	return recordFailure(db, twoFactorConfirmThrottleKey(userID), loginThrottle.MaxAccountFailures)
+}
+
+// ResetTwoFactorConfirmFailures clears the user's counter after one of their codes was accepted
+func ResetTwoFactorConfirmFailures(db *gorm.DB, userID uint) error {
+	return db.Where("throttle_key = ?", twoFactorConfirmThrottleKey(userID)).Delete(&model.LoginThrottle{}).Error
+}
+
+// RecordLoginFailure counts a failed login for the account and the IP, locking either
+// once it reaches its threshold. It reports whether the account or IP is now locked.
+func RecordLoginFailure(db *gorm.DB, email string, ip string) (bool, error) {
+	accountLocked, err := recordFailure(db, accountThrottleKey(email), loginThrottle.MaxAccountFailures)
+	if err != nil {
+		return false, err
+	}
+	ipLocked, err := recordFailure(db, ipThrottleKey(ip), loginThrottle.MaxIPFailures)
+	if err != nil {
+		return false, err
+	}
+	return accountLocked || ipLocked, nil
+}
+
+// ResetLoginFailures clears the account counter after a successful login.
+// The IP counter is kept so one good account cannot reset an attacker's budget.
+func ResetLoginFailures(db *gorm.DB, email string) error {
+	return db.Where("throttle_key = ?", accountThrottleKey(email)).Delete(&model.LoginThrottle{}).Error
+}
+
+// recordFailure increments one counter and locks it when it reaches max. The increment is a
+// single UPDATE and the count is read back in the same transaction, so parallel failures are
+// all counted and exactly one of them locks the counter.
+func recordFailure(db *gorm.DB, key string, max int) (bool, error) {
+	// Create the counter on the first failure, a concurrent insert of the same key is fine
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.LoginThrottle{Key: key}).Error; err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	locked := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Failures outside the window no longer count. failures is assigned before
+		// last_failure_at because MySQL evaluates SET assignments in order.
+		if err := tx.Exec("UPDATE login_throttles SET failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END, last_failure_at = ? WHERE throttle_key = ?",
+			now.Add(-loginThrottle.LockoutDuration), now, key).Error; err != nil {
+			return err
+		}
+
+		// The row stays locked by the UPDATE, so this is the count this failure produced
+		var throttle model.LoginThrottle
+		if err := tx.Where("throttle_key = ?", key).First(&throttle).Error; err != nil {
+			return err
+		}
+		if throttle.Failures < max {
+			return nil
+		}
+
+		lockedUntil := now.Add(loginThrottle.LockoutDuration)
+		if err := tx.Model(&throttle).Updates(map[string]interface{}{"failures": 0, "locked_until": lockedUntil}).Error; err != nil {
+			return err
+		}
+		locked = true
+		log.Printf("Login locked for %s until %s", redactThrottleKey(key), lockedUntil.Format(time.RFC3339))
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return locked, nil
+}
+
+// redactThrottleKey replaces the email in an account's counter key with a hash of it, so logs
+// do not carry addresses but lockouts of one account can still be told apart
+func redactThrottleKey(key string) string {
+	prefix, email, found := strings.Cut(key, accountThrottlePrefix)
+	if !found {
+		return key
+	}
+	return prefix + accountThrottlePrefix + TokenKey(email)[:16]
+}
+
+// progressiveDelay doubles the base delay for each failure, up to the maximum
+func progressiveDelay(failures int) time.Duration {
+	delay := loginThrottle.BaseDelay
+	for i := 1; i < failures && delay < loginThrottle.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, loginThrottle.MaxDelay)
+}
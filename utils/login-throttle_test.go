@@ -0,0 +1,191 @@
+package utils_test
+
+import (
+	"backend/config"
+	"backend/model"
+	"backend/utils"
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setTestThrottle(t *testing.T) {
+	utils.SetLoginThrottleConfig(config.LoginThrottleConfig{
+		MaxAccountFailures: 3,
+		MaxIPFailures:      10,
+		LockoutDuration:    time.Minute,
+		BaseDelay:          time.Second,
+		MaxDelay:           4 * time.Second,
+	})
+	t.Cleanup(func() { utils.SetLoginThrottleConfig(config.Default(config.ProfileTest).Auth.LoginThrottle) })
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		wantLocked bool
+		wantWait   time.Duration // Lower bound of the wait after the failures
+	}{
+		{name: "one failure", failures: 1, wantWait: 900 * time.Millisecond},
+		{name: "progressive delay", failures: 2, wantWait: 1900 * time.Millisecond},
+		{name: "account locked", failures: 3, wantLocked: true, wantWait: 59 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestThrottle(t)
+			db := newTestDB(t)
+
+			var locked bool
+			for i := 0; i < tt.failures; i++ {
+				var err error
+				if locked, err = utils.RecordLoginFailure(db, "user@example.com", "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if locked != tt.wantLocked {
+				t.Fatalf("got locked %v, want %v", locked, tt.wantLocked)
+			}
+
+			wait, err := utils.CheckLoginThrottle(db, "User@Example.com", "10.0.0.2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wait < tt.wantWait {
+				t.Fatalf("got wait %s, want at least %s", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestRecordLoginFailureWindow(t *testing.T) {
+	setTestThrottle(t)
+	db := newTestDB(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := utils.RecordLoginFailure(db, "user@example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Failures older than the lockout duration no longer count
+	if err := db.Model(&model.LoginThrottle{}).Where("throttle_key = ?", "account:user@example.com").
+		Update("last_failure_at", time.Now().Add(-2*time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	locked, err := utils.RecordLoginFailure(db, "user@example.com", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked {
+		t.Fatal("failures outside the window locked the account")
+	}
+}
+
+func TestRecordLoginFailureParallel(t *testing.T) {
+	setTestThrottle(t)
+	db := newTestDB(t)
+
+	// Nine parallel failures from different IPs lock the account exactly three times
+	const failures = 9
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	locks := 0
+	for i := 0; i < failures; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			locked, err := utils.RecordLoginFailure(db, "user@example.com", "10.0.1."+string(rune('0'+i)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if locked {
+				mu.Lock()
+				locks++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if locks != failures/3 {
+		t.Fatalf("got %d lockouts for %d parallel failures, want %d", locks, failures, failures/3)
+	}
+}
+
+func TestPasswordResetThrottleIsSeparate(t *testing.T) {
+	setTestThrottle(t)
+	db := newTestDB(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := utils.RecordPasswordResetRequest(db, "user@example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, err := utils.CheckPasswordResetThrottle(db, "user@example.com", "10.0.0.1"); err != nil || wait == 0 {
+		t.Fatalf("reset requests not throttled: wait %s, error %v", wait, err)
+	}
+	if wait, err := utils.CheckLoginThrottle(db, "user@example.com", "10.0.0.1"); err != nil || wait != 0 {
+		t.Fatalf("reset requests throttled logins: wait %s, error %v", wait, err)
+	}
+}
+
+func TestPasswordConfirmThrottle(t *testing.T) {
+	setTestThrottle(t)
+	db := newTestDB(t)
+
+	for i := 1; i <= 3; i++ {
+		locked, err := utils.RecordPasswordConfirmFailure(db, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != (i == 3) {
+			t.Fatalf("failure %d: got locked %v", i, locked)
+		}
+	}
+	if wait, err := utils.CheckPasswordConfirmThrottle(db, 1); err != nil || wait < 59*time.Second {
+		t.Fatalf("password checks not locked: wait %s, error %v", wait, err)
+	}
+	if wait, err := utils.CheckPasswordConfirmThrottle(db, 2); err != nil || wait != 0 {
+		t.Fatalf("another user's checks throttled: wait %s, error %v", wait, err)
+	}
+
+	if err := utils.ResetPasswordConfirmFailures(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	if wait, err := utils.CheckPasswordConfirmThrottle(db, 1); err != nil || wait != 0 {
+		t.Fatalf("reset did not clear the throttle: wait %s, error %v", wait, err)
+	}
+}
+
+func TestLockoutLogOmitsEmail(t *testing.T) {
+	setTestThrottle(t)
+	db := newTestDB(t)
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	for i := 0; i < 3; i++ {
+		if _, err := utils.RecordLoginFailure(db, "user@example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := utils.RecordPasswordResetRequest(db, "user@example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output := logged.String()
+	if strings.Contains(output, "user@example.com") {
+		t.Fatalf("lockout log carries the email: %s", output)
+	}
+	if !strings.Contains(output, "Login locked for account:") || !strings.Contains(output, "Login locked for reset:account:") {
+		t.Fatalf("lockouts not logged: %s", output)
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"backend/model"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+// Magic links log a user in from an emailed, signed link
+const (
+	MagicLinkTTL      = 15 * time.Minute
+	MagicLinkCookie   = "magic_link_browser" // Ties a link to the browser that asked for it
+	magicLinkType     = "magic_link"
+	magicLinkInterval = time.Minute // Minimum time between two links to the same account
+)
+
+// Define custom error messages
+var (
+	ErrInvalidMagicLink     = errors.New("invalid or expired login link")
+	ErrMagicLinkBrowser     = errors.New("login link was requested from another browser")
+	ErrMagicLinkRateLimited = errors.New("a login link was sent recently")
+)
+
+// NewMagicLinkBrowserID returns a random value identifying the requesting browser
+func NewMagicLinkBrowserID() string {
+	return randomURLSafe(32)
+}
+
+// IssueMagicLink signs a single-use login token for the user, usable only from the browser
+// holding browserID
+func IssueMagicLink(db *gorm.DB, userID uint, browserID string) (string, error) {
+	var latest model.MagicLink
+	err := db.Where("user_id = ?", userID).Order("created_at DESC").First(&latest).Error
+	if err == nil && time.Since(latest.CreatedAt) < magicLinkInterval {
+		return "", ErrMagicLinkRateLimited
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	jti := GenerateVerificationToken()
+	expiration := time.Now().Add(MagicLinkTTL)
+
+	token, err := SignToken(jwt.MapClaims{
+		"user_id": userID,
+		TypeClaim: magicLinkType,
+		"exp":     expiration.Unix(),
+		"iat":     time.Now().Unix(),
+		"jti":     jti,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := db.Create(&model.MagicLink{
+		UserID:      userID,
+		TokenID:     jti,
+		BrowserHash: TokenKey(browserID),
+		ExpiresAt:   expiration,
+	}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeMagicLink checks the signature, expiry, browser and single use of a login link and
+// returns the user it logs in
+func ConsumeMagicLink(db *gorm.DB, token string, browserID string) (uint, error) {
+	parsedToken, err := jwt.Parse(token, verificationKey)
+	if err != nil {
+		return 0, ErrInvalidMagicLink
+	}
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || !parsedToken.Valid || claims[TypeClaim] != magicLinkType {
+		return 0, ErrInvalidMagicLink
+	}
+	jti, _ := claims["jti"].(string)
+
+	var link model.MagicLink
+	if err := db.Where("token_id = ?", jti).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrInvalidMagicLink
+		}
+		return 0, err
+	}
+	if link.UsedAt != nil || link.ExpiresAt.Before(time.Now()) {
+		return 0, ErrInvalidMagicLink
+	}
+
+	// Checked before the link is used up, so a mail scanner opening it does not burn it
+	if browserID == "" || subtle.ConstantTimeCompare([]byte(TokenKey(browserID)), []byte(link.BrowserHash)) != 1 {
+		return 0, ErrMagicLinkBrowser
+	}
+
+	// Losing a concurrent race means the link was already used
+	result := db.Model(&model.MagicLink{}).
+		Where("id = ? AND used_at IS NULL", link.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrInvalidMagicLink
+	}
+	return link.UserID, nil
+}
@@ -0,0 +1,84 @@
+package utils_test
+
+import (
+	"backend/utils"
+	"errors"
+	"testing"
+)
+
+func TestConsumeMagicLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   func(t *testing.T, link string, pair *utils.TokenPair, interim string) string
+		browser string
+		wantErr error
+	}{
+		{
+			name:    "valid link",
+			token:   func(t *testing.T, link string, pair *utils.TokenPair, interim string) string { return link },
+			browser: "browser-1",
+		},
+		{
+			name:    "another browser",
+			token:   func(t *testing.T, link string, pair *utils.TokenPair, interim string) string { return link },
+			browser: "browser-2",
+			wantErr: utils.ErrMagicLinkBrowser,
+		},
+		{
+			name:    "access token",
+			token:   func(t *testing.T, link string, pair *utils.TokenPair, interim string) string { return pair.AccessToken },
+			browser: "browser-1",
+			wantErr: utils.ErrInvalidMagicLink,
+		},
+		{
+			name:    "interim token",
+			token:   func(t *testing.T, link string, pair *utils.TokenPair, interim string) string { return interim },
+			browser: "browser-1",
+			wantErr: utils.ErrInvalidMagicLink,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user := newTestUser(t, db, "user@example.com")
+
+			link, err := utils.IssueMagicLink(db, user.ID, "browser-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pair, err := utils.IssueTokenPair(db, user.ID, false, utils.Device{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			interim, err := utils.IssueInterimToken(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			userID, err := utils.ConsumeMagicLink(db, tt.token(t, link, pair, interim), tt.browser)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && userID != user.ID {
+				t.Fatalf("got user %d, want %d", userID, user.ID)
+			}
+		})
+	}
+}
+
+func TestMagicLinkIsNotAnAccessToken(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "user@example.com")
+
+	link, err := utils.IssueMagicLink(db, user.ID, "browser-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.ValidateToken(link); err == nil {
+		t.Fatal("magic link accepted as an access token")
+	}
+	if _, _, err := utils.ParseInterimToken(link); err == nil {
+		t.Fatal("magic link accepted as an interim token")
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"backend/config"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// mailConfig is the SMTP server emails are sent through, see SetMailConfig
+var mailConfig = config.MailConfig{Port: 2525}
+
+// SetMailConfig replaces the SMTP settings with the validated configuration
+func SetMailConfig(cfg config.MailConfig) {
+	mailConfig = cfg
+
+	// Check the new server on the next call
+	mailCheck.Lock()
+	mailCheck.checkedAt = time.Time{}
+	mailCheck.Unlock()
+}
+
+// ErrMailNotConfigured is returned by CheckMailTransport when no SMTP host is set
+var ErrMailNotConfigured = errors.New("mail host is not configured")
+
+// mailCheckInterval is how long a transport check is reused, so frequent readiness
+// probes do not open an SMTP connection each time
+const mailCheckInterval = 30 * time.Second
+
+var mailCheck struct {
+	sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// CheckMailTransport reports whether the SMTP server accepts connections. The result is
+// cached for mailCheckInterval.
+func CheckMailTransport(timeout time.Duration) error {
+	if mailConfig.Host == "" {
+		return ErrMailNotConfigured
+	}
+
+	mailCheck.Lock()
+	defer mailCheck.Unlock()
+	if !mailCheck.checkedAt.IsZero() && time.Since(mailCheck.checkedAt) < mailCheckInterval {
+		return mailCheck.err
+	}
+	mailCheck.err = dialMailServer(timeout)
+	mailCheck.checkedAt = time.Now()
+	return mailCheck.err
+}
+
+// dialMailServer connects to the SMTP server, waits for its greeting and quits without
+// authenticating. Port 465 uses implicit TLS like the mail senders.
+func dialMailServer(timeout time.Duration) error {
+	addr := net.JoinHostPort(mailConfig.Host, strconv.Itoa(mailConfig.Port))
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	var err error
+	if mailConfig.Port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: mailConfig.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(conn, mailConfig.Host)
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
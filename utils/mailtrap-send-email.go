@@ -2,28 +2,12 @@ package utils
 
 import (
 	"fmt"
+
 	"gopkg.in/gomail.v2"
-	"os"
 )
 
-// SendEmail sends an email using the SMTP configuration from environment variables.
+// SendEmail sends an email using the configured SMTP server.
 func MailtrapSendEmail(to string, subject string, body string, link string) error {
-	from := os.Getenv("MAIL_FROM_ADDRESS")
-	password := os.Getenv("MAIL_PASSWORD")
-	smtpHost := os.Getenv("MAIL_HOST")
-	smtpPort := os.Getenv("MAIL_PORT")
-	username := os.Getenv("MAIL_USERNAME")
-
-	fmt.Println("MAIL_FROM_ADDRESS:", from)
-	fmt.Println("MAIL_USERNAME:", username)
-	fmt.Println("MAIL_PASSWORD:", password)
-	fmt.Println("MAIL_HOST:", smtpHost)
-	fmt.Println("MAIL_PORT:", smtpPort)
-
-	// Convert smtpPort from string to int
-	port := 2525 // Default Mailtrap port for example, replace if necessary
-	fmt.Sscanf(smtpPort, "%d", &port)
-
 	// Use fmt.Sprintf for clean and readable HTML body construction
 	htmlBody := fmt.Sprintf(`
 		<html>
@@ -40,7 +24,7 @@ func MailtrapSendEmail(to string, subject string, body string, link string) erro
 	m := gomail.NewMessage()
 
 	// Set the sender address
-	m.SetHeader("From", from)
+	m.SetHeader("From", mailConfig.From)
 
 	// Set the recipient address
 	m.SetHeader("To", to)
@@ -52,13 +36,12 @@ func MailtrapSendEmail(to string, subject string, body string, link string) erro
 	m.SetBody("text/html", htmlBody)
 
 	// Create a new SMTP dialer
-	d := gomail.NewDialer(smtpHost, port, username, password)
+	d := gomail.NewDialer(mailConfig.Host, mailConfig.Port, mailConfig.Username, mailConfig.Password)
 
 	// Send the email
 	if err := d.DialAndSend(m); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
-	fmt.Println("Email sent successfully to:", to)
 	return nil
 }
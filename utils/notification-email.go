@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SendNotificationEmail sends an informational email without a call-to-action link
+func SendNotificationEmail(to string, subject string, body string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", mailConfig.From)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", fmt.Sprintf("<html><body><p>%s</p></body></html>", html.EscapeString(body)))
+
+	d := gomail.NewDialer(mailConfig.Host, mailConfig.Port, mailConfig.Username, mailConfig.Password)
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
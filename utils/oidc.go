@@ -0,0 +1,465 @@
+package utils
+
+import (
+	"backend/config"
+	"backend/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+// OIDCLoginTTL is how long the user has to come back from the provider
+const OIDCLoginTTL = 10 * time.Minute
+
+// OIDCStateCookie holds the state of a login in the browser that started it, so a callback
+// carrying someone else's state is rejected
+const OIDCStateCookie = "oidc_state"
+
+// oidcKeysRefreshInterval limits how often an unknown kid triggers a JWKS refetch
+const oidcKeysRefreshInterval = time.Minute
+
+// Define custom error messages
+var (
+	ErrUnknownOIDCProvider  = errors.New("unknown identity provider")
+	ErrInvalidOIDCState     = errors.New("invalid or expired login state")
+	ErrInvalidIDToken       = errors.New("invalid ID token")
+	ErrOIDCEmailNotVerified = errors.New("the provider has not verified this email address")
+	ErrOIDCNoAccount        = errors.New("no account matches this identity")
+)
+
+// oidcHTTPClient talks to the providers' discovery, token and JWKS endpoints
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// OIDCProvider is an OpenID Connect provider users can sign in with
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// oidcDiscovery holds the parts of the provider's openid-configuration we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDTokenClaims are the verified claims of an ID token
+type IDTokenClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Configured providers by name
+var (
+	oidcProvidersMu sync.RWMutex
+	oidcProviders   = map[string]*OIDCProvider{}
+)
+
+// SetOIDCProviders replaces the configured providers
+func SetOIDCProviders(providers ...*OIDCProvider) {
+	byName := make(map[string]*OIDCProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name] = provider
+	}
+
+	oidcProvidersMu.Lock()
+	defer oidcProvidersMu.Unlock()
+	oidcProviders = byName
+}
+
+// GetOIDCProvider returns the provider with the given name
+func GetOIDCProvider(name string) (*OIDCProvider, error) {
+	oidcProvidersMu.RLock()
+	defer oidcProvidersMu.RUnlock()
+	provider, ok := oidcProviders[name]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+	return provider, nil
+}
+
+// LoadOIDCProviders registers the configured providers, scopes default to openid, email and profile
+func LoadOIDCProviders(configs []config.OIDCProviderConfig) error {
+	var providers []*OIDCProvider
+	for _, cfg := range configs {
+		provider := &OIDCProvider{
+			Name:         cfg.Name,
+			Issuer:       strings.TrimSuffix(cfg.Issuer, "/"),
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+		}
+		if len(cfg.Scopes) > 0 {
+			provider.Scopes = cfg.Scopes
+		}
+		if provider.Name == "" || provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return fmt.Errorf("OIDC provider %q needs a name, issuer, client_id and redirect_url", cfg.Name)
+		}
+		providers = append(providers, provider)
+	}
+
+	SetOIDCProviders(providers...)
+	return nil
+}
+
+// StartOIDCLogin remembers a new state, nonce and PKCE verifier and returns the provider URL to
+// send the user to and the state, which the caller stores in OIDCStateCookie
+func StartOIDCLogin(db *gorm.DB, provider *OIDCProvider) (string, string, error) {
+	discovery, err := provider.discover()
+	if err != nil {
+		return "", "", err
+	}
+
+	state := randomURLSafe(32)
+	login := model.OIDCLogin{
+		StateHash:    TokenKey(state),
+		Provider:     provider.Name,
+		Nonce:        randomURLSafe(32),
+		CodeVerifier: randomURLSafe(32),
+		ExpiresAt:    time.Now().Add(OIDCLoginTTL),
+	}
+
+	// Drop abandoned logins while we are here
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&model.OIDCLogin{}).Error; err != nil {
+		return "", "", err
+	}
+	if err := db.Create(&login).Error; err != nil {
+		return "", "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientID},
+		"redirect_uri":          {provider.RedirectURL},
+		"scope":                 {strings.Join(provider.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {login.Nonce},
+		"code_challenge":        {PKCEChallenge(login.CodeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// FinishOIDCLogin consumes the state, exchanges the code and returns the verified ID token claims.
+// browserState is the OIDCStateCookie of the request, it must match the state.
+func FinishOIDCLogin(db *gorm.DB, provider *OIDCProvider, state, browserState, code string) (*IDTokenClaims, error) {
+	// Checked before the state is used up, so a forged callback cannot burn the user's login
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	var login model.OIDCLogin
+	if err := db.Where("state_hash = ? AND provider = ?", TokenKey(state), provider.Name).First(&login).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+
+	// The state is single use, whoever deletes it first wins
+	result := db.Delete(&login)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || login.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	idToken, err := provider.exchange(code, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	return provider.VerifyIDToken(idToken, login.Nonce)
+}
+
+// LinkOIDCIdentity returns the user an external identity belongs to. Identities seen for the first
+// time are linked to the user with the same email, provided the provider verified that email.
+func LinkOIDCIdentity(db *gorm.DB, providerName string, claims *IDTokenClaims) (*model.User, error) {
+	var identity model.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error
+	if err == nil {
+		var user model.User
+		if err := db.First(&user, identity.UserID).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	var user model.User
+	if err := db.Where("email = ?", claims.Email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOIDCNoAccount
+		}
+		return nil, err
+	}
+
+	identity = model.UserIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&identity).Error; err != nil {
+			return err
+		}
+		// The provider vouches for the address, so the account counts as verified
+		return tx.Model(&user).Update("is_verified", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// VerifyIDToken checks the signature of an ID token against the provider's JWKS and validates
+// its issuer, audience, expiry and nonce
+func (p *OIDCProvider) VerifyIDToken(idToken, nonce string) (*IDTokenClaims, error) {
+	token, err := jwt.Parse(idToken, p.verificationKey)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidIDToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidIDToken
+	}
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return nil, ErrInvalidIDToken
+	}
+	if !slices.Contains(audienceClaim(claims), p.ClientID) {
+		return nil, ErrInvalidIDToken
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, ErrInvalidIDToken
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, ErrInvalidIDToken
+	}
+
+	result := &IDTokenClaims{}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		// Some providers send the flag as a string
+		result.EmailVerified = verified == "true"
+	}
+	if result.Subject == "" {
+		return nil, ErrInvalidIDToken
+	}
+	return result, nil
+}
+
+// verificationKey resolves the kid of an ID token to one of the provider's public keys
+func (p *OIDCProvider) verificationKey(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *SigningMethodEdDSA:
+	default:
+		// Never accept HMAC or unsigned ID tokens
+		return nil, ErrInvalidSigningMethod
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, err := p.publicKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// publicKey returns the provider key with the kid, refetching the JWKS when the kid is unknown
+func (p *OIDCProvider) publicKey(kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcKeysRefreshInterval {
+		return nil, ErrUnknownKeyID
+	}
+
+	discovery, err := p.discoverLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := getJSON(discovery.JWKSURI, &document); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	return key, nil
+}
+
+// exchange trades the authorization code and PKCE verifier for an ID token
+func (p *OIDCProvider) exchange(code, codeVerifier string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	request, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	response, err := oidcHTTPClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint of %s: %w", p.Name, err)
+	}
+	if response.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint of %s: %s %s", p.Name, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("token endpoint of %s returned no id_token", p.Name)
+	}
+	return body.IDToken, nil
+}
+
+// discover fetches and caches the provider's openid-configuration
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.discoverLocked()
+}
+
+func (p *OIDCProvider) discoverLocked() (*oidcDiscovery, error) {
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("OIDC provider %s reports issuer %q", p.Name, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC provider %s has an incomplete configuration", p.Name)
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// getJSON decodes the JSON document at the URL
+func getJSON(target string, out interface{}) error {
+	response, err := oidcHTTPClient.Get(target)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", target, response.Status)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(out)
+}
+
+// audienceClaim returns the aud claim, which may be a string or a list
+func audienceClaim(claims jwt.MapClaims) []string {
+	if aud, ok := claims["aud"].(string); ok {
+		return []string{aud}
+	}
+	return stringsClaim(claims, "aud")
+}
+
+// PKCEChallenge derives the S256 code challenge of a PKCE verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomURLSafe returns n random bytes encoded as unpadded base64url
+func randomURLSafe(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,252 @@
+package utils_test
+
+import (
+	"backend/model"
+	"backend/utils"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+// mockOIDCProvider is an identity provider serving discovery, JWKS and the token endpoint.
+// Codes are handed out with authorize and exchanged once for an ID token.
+type mockOIDCProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+}
+
+// mockAuthorization is what the provider remembers about an issued code
+type mockAuthorization struct {
+	challenge string
+	claims    jwt.MapClaims
+	kid       string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDCProvider{key: key, clientID: "test-client", codes: map[string]mockAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []utils.JWK{{
+			Kty: "RSA",
+			Kid: "k1",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.mu.Lock()
+		authorization, ok := m.codes[r.PostForm.Get("code")]
+		delete(m.codes, r.PostForm.Get("code"))
+		m.mu.Unlock()
+
+		if !ok || utils.PKCEChallenge(r.PostForm.Get("code_verifier")) != authorization.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, authorization.claims)
+		token.Header["kid"] = authorization.kid
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// provider returns a client of the mock provider with an empty key cache
+func (m *mockOIDCProvider) provider() *utils.OIDCProvider {
+	return &utils.OIDCProvider{
+		Name:        "mock",
+		Issuer:      m.server.URL,
+		ClientID:    m.clientID,
+		RedirectURL: "http://localhost/api/person/oidc/mock/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+}
+
+// authorize signs the user in at the provider for the authorization URL and returns the state
+// and code the provider redirects back with. edit may change the issued code before it is stored.
+func (m *mockOIDCProvider) authorize(t *testing.T, authURL string, edit func(*mockAuthorization)) (string, string) {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("got code_challenge_method %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	authorization := mockAuthorization{
+		challenge: query.Get("code_challenge"),
+		kid:       "k1",
+		claims: jwt.MapClaims{
+			"iss":            m.server.URL,
+			"aud":            m.clientID,
+			"sub":            "subject-1",
+			"email":          "user@example.com",
+			"email_verified": true,
+			"nonce":          query.Get("nonce"),
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		},
+	}
+	if edit != nil {
+		edit(&authorization)
+	}
+
+	code := utils.GenerateVerificationToken()
+	m.mu.Lock()
+	m.codes[code] = authorization
+	m.mu.Unlock()
+	return query.Get("state"), code
+}
+
+func TestOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name         string
+		edit         func(*mockAuthorization)
+		prepare      func(t *testing.T, db *gorm.DB) // Runs between the redirect and the callback
+		browserState func(state string) string
+		wantErr      error // nil for a successful login, errAny for any error
+	}{
+		{name: "valid login"},
+		{
+			name:         "state cookie missing",
+			browserState: func(string) string { return "" },
+			wantErr:      utils.ErrInvalidOIDCState,
+		},
+		{
+			name:         "state cookie of another login",
+			browserState: func(string) string { return "other-state" },
+			wantErr:      utils.ErrInvalidOIDCState,
+		},
+		{
+			name: "expired state",
+			prepare: func(t *testing.T, db *gorm.DB) {
+				if err := db.Model(&model.OIDCLogin{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: utils.ErrInvalidOIDCState,
+		},
+		{
+			name:    "PKCE verifier mismatch",
+			edit:    func(a *mockAuthorization) { a.challenge = utils.PKCEChallenge("another-verifier") },
+			wantErr: errAny,
+		},
+		{
+			name:    "nonce mismatch",
+			edit:    func(a *mockAuthorization) { a.claims["nonce"] = "another-nonce" },
+			wantErr: utils.ErrInvalidIDToken,
+		},
+		{
+			name:    "unknown kid",
+			edit:    func(a *mockAuthorization) { a.kid = "k2" },
+			wantErr: utils.ErrInvalidIDToken,
+		},
+		{
+			name:    "wrong audience",
+			edit:    func(a *mockAuthorization) { a.claims["aud"] = "another-client" },
+			wantErr: utils.ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			mock := newMockOIDCProvider(t)
+			provider := mock.provider()
+
+			authURL, cookieState, err := utils.StartOIDCLogin(db, provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state, code := mock.authorize(t, authURL, tt.edit)
+			if state != cookieState {
+				t.Fatalf("authorization URL has state %q, cookie has %q", state, cookieState)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, db)
+			}
+			if tt.browserState != nil {
+				cookieState = tt.browserState(state)
+			}
+
+			claims, err := utils.FinishOIDCLogin(db, provider, state, cookieState, code)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("login failed: %v", err)
+			case tt.wantErr == nil:
+				if claims.Subject != "subject-1" || claims.Email != "user@example.com" || !claims.EmailVerified {
+					t.Fatalf("got claims %+v", claims)
+				}
+			case err == nil:
+				t.Fatal("login succeeded, want an error")
+			case tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	db := newTestDB(t)
+	mock := newMockOIDCProvider(t)
+	provider := mock.provider()
+
+	authURL, cookieState, err := utils.StartOIDCLogin(db, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := mock.authorize(t, authURL, nil)
+	if _, err := utils.FinishOIDCLogin(db, provider, state, cookieState, code); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.FinishOIDCLogin(db, provider, state, cookieState, code); !errors.Is(err, utils.ErrInvalidOIDCState) {
+		t.Fatalf("got error %v replaying the state, want %v", err, utils.ErrInvalidOIDCState)
+	}
+}
+
+// errAny matches any error in table tests
+var errAny = errors.New("any error")
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"backend/config"
+	"backend/model"
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// ErrPasswordReused is returned when a new password matches one of the user's recent passwords
+var ErrPasswordReused = errors.New("password was used recently, choose a different one")
+
+// PasswordPolicy is the set of rules new passwords must follow
+type PasswordPolicy struct {
+	MinLength     int                 // Minimum number of characters
+	MaxLength     int                 // Maximum number of characters
+	RequireUpper  bool                // At least one upper case letter
+	RequireLower  bool                // At least one lower case letter
+	RequireDigit  bool                // At least one digit
+	RequireSymbol bool                // At least one character that is not a letter or digit
+	HistorySize   int                 // Number of previous passwords, besides the current one, that cannot be reused
+	Denylist      map[string]struct{} // Common or breached passwords, lower case
+}
+
+// passwordPolicy is the active policy, see LoadPasswordPolicy
+var passwordPolicy = PasswordPolicy{
+	MinLength:   8,
+	MaxLength:   128,
+	HistorySize: 5,
+	Denylist:    map[string]struct{}{},
+}
+
+// LoadPasswordPolicy replaces the defaults with the validated configuration and loads the
+// denylist file it names
+func LoadPasswordPolicy(cfg config.PasswordPolicyConfig) error {
+	policy := PasswordPolicy{
+		MinLength:     cfg.MinLength,
+		MaxLength:     cfg.MaxLength,
+		RequireUpper:  cfg.RequireUpper,
+		RequireLower:  cfg.RequireLower,
+		RequireDigit:  cfg.RequireDigit,
+		RequireSymbol: cfg.RequireSymbol,
+		HistorySize:   cfg.HistorySize,
+		Denylist:      map[string]struct{}{},
+	}
+
+	if cfg.DenylistFile != "" {
+		denylist, err := loadPasswordDenylist(cfg.DenylistFile)
+		if err != nil {
+			return err
+		}
+		policy.Denylist = denylist
+	}
+	passwordPolicy = policy
+	return nil
+}
+
+// loadPasswordDenylist reads one password per line, blank lines and lines starting with # are skipped
+func loadPasswordDenylist(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open password denylist: %w", err)
+	}
+	defer file.Close()
+
+	denylist := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read password denylist: %w", err)
+	}
+	return denylist, nil
+}
+
+// ValidatePassword checks a new password against the policy rules that do not need the user's history
+func ValidatePassword(password string) error {
+	var problems []string
+
+	length := utf8.RuneCountInString(password)
+	if length < passwordPolicy.MinLength {
+		problems = append(problems, fmt.Sprintf("be at least %d characters long", passwordPolicy.MinLength))
+	}
+	if length > passwordPolicy.MaxLength {
+		problems = append(problems, fmt.Sprintf("be at most %d characters long", passwordPolicy.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if passwordPolicy.RequireUpper && !upper {
+		problems = append(problems, "contain an upper case letter")
+	}
+	if passwordPolicy.RequireLower && !lower {
+		problems = append(problems, "contain a lower case letter")
+	}
+	if passwordPolicy.RequireDigit && !digit {
+		problems = append(problems, "contain a digit")
+	}
+	if passwordPolicy.RequireSymbol && !symbol {
+		problems = append(problems, "contain a symbol")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("password must %s", strings.Join(problems, ", "))
+	}
+
+	if _, denied := passwordPolicy.Denylist[strings.ToLower(password)]; denied {
+		return errors.New("password is too common, choose a different one")
+	}
+	return nil
+}
+
+// ValidatePasswordChange checks a new password of an existing user against the policy and
+// the user's current and recent passwords
+func ValidatePasswordChange(db *gorm.DB, user *model.User, password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	hashes := []string{user.Password}
+	var history []model.PasswordHistory
+	if err := db.Where("user_id = ?", user.ID).Order("created_at DESC, id DESC").Limit(passwordPolicy.HistorySize).Find(&history).Error; err != nil {
+		return err
+	}
+	for _, entry := range history {
+		hashes = append(hashes, entry.Hash)
+	}
+
+	for _, hash := range hashes {
+		if ok, _, _ := VerifyPassword(hash, password); ok {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// SetPassword stores a new password for the user and remembers the replaced one for the reuse check
+func SetPassword(db *gorm.DB, user *model.User, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := recordPasswordHistory(tx, user.ID, user.Password); err != nil {
+			return err
+		}
+		if err := tx.Model(&model.User{}).Where("id = ?", user.ID).Update("password", hash).Error; err != nil {
+			return err
+		}
+		user.Password = hash
+		return nil
+	})
+}
+
+// recordPasswordHistory remembers a previous password hash of the user, keeping only as many as the policy checks
+func recordPasswordHistory(db *gorm.DB, userID uint, hash string) error {
+	if passwordPolicy.HistorySize == 0 {
+		return nil
+	}
+
+	if err := db.Create(&model.PasswordHistory{UserID: userID, Hash: hash}).Error; err != nil {
+		return err
+	}
+
+	// Drop entries beyond the configured history size
+	var ids []uint
+	if err := db.Model(&model.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) <= passwordPolicy.HistorySize {
+		return nil
+	}
+	return db.Delete(&model.PasswordHistory{}, ids[passwordPolicy.HistorySize:]).Error
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Principal types
+const (
+	PrincipalUser   = "user"
+	PrincipalAPIKey = "api_key"
+)
+
+// Principal is the authenticated caller that AuthMiddleware stores in c.Locals("principal"),
+// whether it presented a bearer token or an API key
+type Principal struct {
+	Type             string   `json:"type"`
+	UserID           uint     `json:"user_id,omitempty"`            // The user, or the owner of a user API key
+	ServiceAccountID uint     `json:"service_account_id,omitempty"` // The owner of a service account API key
+	APIKeyID         uint     `json:"api_key_id,omitempty"`
+	Roles            []string `json:"roles"`
+	Permissions      []string `json:"permissions"`
+	MFA              bool     `json:"mfa"`                // The login passed two-factor authentication
+	ActorID          uint     `json:"actor_id,omitempty"` // The admin impersonating UserID, from the act claim
+}
+
+// PrincipalFromClaims builds the principal of a validated access token
+func PrincipalFromClaims(claims jwt.MapClaims) (*Principal, error) {
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	mfa, _ := claims["mfa"].(bool)
+	principal := &Principal{
+		Type:        PrincipalUser,
+		UserID:      uint(userID),
+		Roles:       stringsClaim(claims, "roles"),
+		Permissions: stringsClaim(claims, "permissions"),
+		MFA:         mfa,
+	}
+
+	// Impersonation tokens name the acting admin in the act claim
+	if act, ok := claims[ActorClaim].(map[string]interface{}); ok {
+		subject, _ := act["sub"].(string)
+		actorID, err := strconv.ParseUint(subject, 10, 64)
+		if err != nil || actorID == 0 {
+			return nil, ErrInvalidToken
+		}
+		principal.ActorID = uint(actorID)
+	}
+	return principal, nil
+}
+
+// Impersonated reports whether an admin is acting as the user
+func (p *Principal) Impersonated() bool {
+	return p.ActorID != 0
+}
+
+// HasPermission reports whether the principal is granted the permission
+func (p *Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+// HasRole reports whether the principal has the role
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// stringsClaim reads a string list claim, which is []interface{} once a token has been parsed
+func stringsClaim(claims jwt.MapClaims, claim string) []string {
+	values := []string{}
+	switch list := claims[claim].(type) {
+	case []interface{}:
+		for _, v := range list {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case []string:
+		values = append(values, list...)
+	}
+	return values
+}
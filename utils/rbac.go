@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"backend/model"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+// UserClaims loads the roles and permissions carried in a user's access tokens. mfa tells whether
+// the login the tokens belong to passed two-factor authentication.
+func UserClaims(db *gorm.DB, userID uint, mfa bool) (jwt.MapClaims, error) {
+	var user model.User
+	if err := db.Preload("Roles.Permissions").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	roles, permissions := roleNames(user.Roles)
+
+	return jwt.MapClaims{
+		"roles":       roles,
+		"permissions": permissions,
+		"mfa":         mfa,
+	}, nil
+}
+
+// ServiceAccountPermissions loads the permission names granted to a service account through its roles
+func ServiceAccountPermissions(db *gorm.DB, serviceAccountID uint) ([]string, error) {
+	var account model.ServiceAccount
+	if err := db.Preload("Roles.Permissions").First(&account, serviceAccountID).Error; err != nil {
+		return nil, err
+	}
+
+	_, permissions := roleNames(account.Roles)
+	return permissions, nil
+}
+
+// roleNames flattens roles into their names and the distinct names of their permissions
+func roleNames(roles []model.Role) ([]string, []string) {
+	names := []string{}
+	permissions := []string{}
+	seen := map[string]bool{}
+	for _, role := range roles {
+		names = append(names, role.Name)
+		for _, permission := range role.Permissions {
+			if !seen[permission.Name] {
+				seen[permission.Name] = true
+				permissions = append(permissions, permission.Name)
+			}
+		}
+	}
+	return names, permissions
+}
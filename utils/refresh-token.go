@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"backend/model"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TokenPair is the access and refresh token handed to clients on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// IssueTokenPair starts a new refresh token family for the user, recorded as a session of the device.
+// mfa is kept for the whole family, set when the login passed two-factor authentication.
+func IssueTokenPair(db *gorm.DB, userID uint, mfa bool, device Device) (*TokenPair, error) {
+	family := GenerateVerificationToken()
+	if err := createSession(db, userID, family, device); err != nil {
+		return nil, err
+	}
+	return issueTokenPair(db, userID, family, mfa)
+}
+
+// issueTokenPair creates a refresh token in the given family and a matching access token
+func issueTokenPair(db *gorm.DB, userID uint, family string, mfa bool) (*TokenPair, error) {
+	refreshToken := GenerateVerificationToken()
+
+	record := model.RefreshToken{
+		UserID:    userID,
+		Family:    family,
+		TokenHash: TokenKey(refreshToken),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+		MFA:       mfa,
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	// Carry the user's current roles and permissions in the access token
+	claims, err := UserClaims(db, userID, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := generateAccessToken(userID, family, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new pair in the same family.
+// Presenting a token that was already rotated revokes the whole family.
+func RotateRefreshToken(db *gorm.DB, refreshToken string, device Device) (*TokenPair, error) {
+	var record model.RefreshToken
+	if err := db.Where("token_hash = ?", TokenKey(refreshToken)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	if record.RevokedAt != nil {
+		return nil, ErrTokenNotFound
+	}
+	if record.RotatedAt != nil {
+		return nil, reuseDetected(db, record.Family)
+	}
+	if record.ExpiresAt.Before(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+
+	// Mark the token as rotated, losing a concurrent race counts as reuse
+	result := db.Model(&model.RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", record.ID).
+		Update("rotated_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, reuseDetected(db, record.Family)
+	}
+
+	if err := touchSession(db, record.Family, device); err != nil {
+		return nil, err
+	}
+
+	return issueTokenPair(db, record.UserID, record.Family, record.MFA)
+}
+
+// reuseDetected revokes the family of a replayed refresh token
+func reuseDetected(db *gorm.DB, family string) error {
+	log.Printf("Refresh token reuse detected, revoking token family %s", family)
+	if err := RevokeTokenFamily(db, family); err != nil {
+		return err
+	}
+	return ErrTokenReused
+}
+
+// RevokeTokenFamily ends the session of the family, revoking its refresh tokens and deleting its active access tokens
+func RevokeTokenFamily(db *gorm.DB, family string) error {
+	if err := db.Model(&model.RefreshToken{}).
+		Where("family = ? AND revoked_at IS NULL", family).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&model.Session{}).
+		Where("family = ? AND revoked_at IS NULL", family).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	if _, err := tokenStore.DeleteFamily(family); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeUserTokens ends every session of the user, revoking their refresh tokens and deleting their active access tokens
+func RevokeUserTokens(db *gorm.DB, userID uint) error {
+	if err := db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return DeleteUserTokens(userID)
+}
@@ -0,0 +1,97 @@
+package utils_test
+
+import (
+	"backend/model"
+	"backend/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	device := utils.Device{UserAgent: "test", IP: "127.0.0.1"}
+
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, db *gorm.DB, pair *utils.TokenPair) string // Returns the token to rotate
+		wantErr error
+	}{
+		{
+			name:    "valid token",
+			prepare: func(t *testing.T, db *gorm.DB, pair *utils.TokenPair) string { return pair.RefreshToken },
+		},
+		{
+			name:    "unknown token",
+			prepare: func(t *testing.T, db *gorm.DB, pair *utils.TokenPair) string { return "unknown" },
+			wantErr: utils.ErrTokenNotFound,
+		},
+		{
+			name: "expired token",
+			prepare: func(t *testing.T, db *gorm.DB, pair *utils.TokenPair) string {
+				if err := db.Model(&model.RefreshToken{}).Where("token_hash = ?", utils.TokenKey(pair.RefreshToken)).
+					Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+					t.Fatal(err)
+				}
+				return pair.RefreshToken
+			},
+			wantErr: utils.ErrTokenExpired,
+		},
+		{
+			name: "rotated token is reused",
+			prepare: func(t *testing.T, db *gorm.DB, pair *utils.TokenPair) string {
+				if _, err := utils.RotateRefreshToken(db, pair.RefreshToken, device); err != nil {
+					t.Fatal(err)
+				}
+				return pair.RefreshToken
+			},
+			wantErr: utils.ErrTokenReused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user := newTestUser(t, db, "user@example.com")
+			pair, err := utils.IssueTokenPair(db, user.ID, false, device)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next, err := utils.RotateRefreshToken(db, tt.prepare(t, db, pair), device)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (next.RefreshToken == pair.RefreshToken || next.AccessToken == "") {
+				t.Fatalf("got %+v, want a new token pair", next)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "user@example.com")
+	device := utils.Device{UserAgent: "test", IP: "127.0.0.1"}
+
+	first, err := utils.IssueTokenPair(db, user.ID, false, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := utils.RotateRefreshToken(db, first.RefreshToken, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the first token ends the whole family
+	if _, err := utils.RotateRefreshToken(db, first.RefreshToken, device); !errors.Is(err, utils.ErrTokenReused) {
+		t.Fatalf("got error %v, want %v", err, utils.ErrTokenReused)
+	}
+	if _, err := utils.RotateRefreshToken(db, second.RefreshToken, device); !errors.Is(err, utils.ErrTokenNotFound) {
+		t.Fatalf("refresh token of a revoked family: got error %v, want %v", err, utils.ErrTokenNotFound)
+	}
+	if _, err := utils.ValidateToken(second.AccessToken); err == nil {
+		t.Fatal("access token of a revoked family is still valid")
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"backend/model"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecordSecurityEvent adds an event to the user's security timeline, failures are only logged
+func RecordSecurityEvent(db *gorm.DB, event model.SecurityEvent) {
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("Could not record security event %s for user %d: %v", event.Type, event.UserID, err)
+	}
+}
+
+// RecordLoginSuccess records a completed login and emails the user when it comes from a device
+// or IP address they have not logged in from before
+func RecordLoginSuccess(db *gorm.DB, userID uint, device Device) {
+	var user model.User
+	if err := db.Select("id", "email").First(&user, userID).Error; err != nil {
+		log.Printf("Could not load user %d for security event: %v", userID, err)
+		return
+	}
+
+	event := model.SecurityEvent{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Type:      model.SecurityLoginSuccess,
+		IP:        device.IP,
+		UserAgent: device.UserAgent,
+	}
+
+	newDevice, err := isNewDevice(db, user.ID, device)
+	if err != nil {
+		log.Printf("Could not check known devices of user %d: %v", user.ID, err)
+	}
+	RecordSecurityEvent(db, event)
+	if !newDevice {
+		return
+	}
+
+	event.ID = 0
+	event.Type = model.SecurityLoginNewDevice
+	RecordSecurityEvent(db, event)
+
+	// Sent in the background so a slow mail server does not hold up the login
+	RunInBackground(func() {
+		body := fmt.Sprintf("Your account was logged in to on %s from a new device or location (IP address %s, browser %q). "+
+			"If this was not you, reset your password and log out your other sessions.",
+			time.Now().UTC().Format("2 Jan 2006 15:04 MST"), device.IP, device.UserAgent)
+		if err := SendNotificationEmail(user.Email, "New login to your account", body); err != nil {
+			log.Printf("Could not send new device alert to user %d: %v", user.ID, err)
+		}
+	})
+}
+
+// isNewDevice reports whether the user has logged in before, but never with this user agent or
+// from this IP address. The first login of an account is not reported.
+func isNewDevice(db *gorm.DB, userID uint, device Device) (bool, error) {
+	logins := db.Model(&model.SecurityEvent{}).Where("user_id = ? AND type = ?", userID, model.SecurityLoginSuccess)
+
+	var total int64
+	if err := logins.Session(&gorm.Session{}).Count(&total).Error; err != nil || total == 0 {
+		return false, err
+	}
+
+	var sameIP, sameAgent int64
+	if err := logins.Session(&gorm.Session{}).Where("ip = ?", device.IP).Count(&sameIP).Error; err != nil {
+		return false, err
+	}
+	if err := logins.Session(&gorm.Session{}).Where("user_agent = ?", device.UserAgent).Count(&sameAgent).Error; err != nil {
+		return false, err
+	}
+	return sameIP == 0 || sameAgent == 0, nil
+}
+
+// ListSecurityEvents returns the user's most recent security events, newest first
+func ListSecurityEvents(db *gorm.DB, userID uint, limit int) ([]model.SecurityEvent, error) {
+	var events []model.SecurityEvent
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
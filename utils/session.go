@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Device describes the client a login or refresh came from
+type Device struct {
+	UserAgent string
+	IP        string
+}
+
+// createSession records a new login of the user from the device
+func createSession(db *gorm.DB, userID uint, family string, device Device) error {
+	now := time.Now()
+	return db.Create(&model.Session{
+		UserID:     userID,
+		Family:     family,
+		UserAgent:  device.UserAgent,
+		IP:         device.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}).Error
+}
+
+// touchSession updates when and from where the session was last used
+func touchSession(db *gorm.DB, family string, device Device) error {
+	return db.Model(&model.Session{}).Where("family = ?", family).Updates(map[string]interface{}{
+		"last_seen_at": time.Now(),
+		"ip":           device.IP,
+	}).Error
+}
+
+// ListSessions returns the user's active sessions, most recently used first
+func ListSessions(db *gorm.DB, userID uint) ([]model.Session, error) {
+	var sessions []model.Session
+	if err := db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs one of the user's sessions out
+func RevokeSession(db *gorm.DB, userID uint, sessionID uint) error {
+	var session model.Session
+	if err := db.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).First(&session).Error; err != nil {
+		return err
+	}
+	return RevokeTokenFamily(db, session.Family)
+}
+
+// RevokeOtherSessions logs out every session of the user except the one with keepFamily
+func RevokeOtherSessions(db *gorm.DB, userID uint, keepFamily string) (int, error) {
+	var sessions []model.Session
+	if err := db.Where("user_id = ? AND family <> ? AND revoked_at IS NULL", userID, keepFamily).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+	for _, session := range sessions {
+		if err := RevokeTokenFamily(db, session.Family); err != nil {
+			return 0, err
+		}
+	}
+	return len(sessions), nil
+}
@@ -0,0 +1,293 @@
+package utils
+
+import (
+	"backend/config"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Define custom error messages
+var (
+	ErrNoSigningKey  = errors.New("no signing key configured")
+	ErrUnknownKeyID  = errors.New("unknown signing key")
+	ErrInvalidKeyPEM = errors.New("key must be PEM encoded")
+)
+
+// SigningKey is a JWT key identified by its kid. Keys without a SignKey only verify tokens.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// JWK is a public key as published in the JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// PublicKey decodes the RSA, EC or Ed25519 public key of the JWK
+func (k JWK) PublicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: %w", k.Kid, err)
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: %w", k.Kid, err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwk %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: %w", k.Kid, err)
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: %w", k.Kid, err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("jwk %s: point is not on curve %s", k.Kid, k.Crv)
+		}
+		return key, nil
+
+	case "OKP":
+		x, err := decode(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk %s: invalid Ed25519 key", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("jwk %s: unsupported key type %q", k.Kid, k.Kty)
+}
+
+// Signing keys by kid and the kid new tokens are signed with
+var (
+	signingKeysMu sync.RWMutex
+	signingKeys   = map[string]*SigningKey{}
+	activeKeyID   string
+)
+
+// SetSigningKeys replaces the configured keys and selects the one used to sign new tokens
+func SetSigningKeys(activeID string, keys ...*SigningKey) error {
+	byID := make(map[string]*SigningKey, len(keys))
+	for _, key := range keys {
+		if _, exists := byID[key.ID]; exists {
+			return fmt.Errorf("duplicate signing key id %q", key.ID)
+		}
+		byID[key.ID] = key
+	}
+
+	active, ok := byID[activeID]
+	if !ok {
+		return fmt.Errorf("active signing key %q is not configured", activeID)
+	}
+	if active.SignKey == nil {
+		return fmt.Errorf("active signing key %q has no private key", activeID)
+	}
+
+	signingKeysMu.Lock()
+	defer signingKeysMu.Unlock()
+	signingKeys = byID
+	activeKeyID = activeID
+	return nil
+}
+
+// LoadSigningKeys loads the configured keys ("kid:alg:path" entries) with the active kid,
+// falling back to a single HS256 key built from the secret
+func LoadSigningKeys(cfg config.JWTConfig) error {
+	if len(cfg.Keys) == 0 {
+		if cfg.Secret == "" {
+			return errors.New("no JWT signing keys configured, set JWT_KEYS or JWT_SECRET")
+		}
+		return SetSigningKeys("default", &SigningKey{
+			ID:        "default",
+			Method:    jwt.SigningMethodHS256,
+			SignKey:   []byte(cfg.Secret),
+			VerifyKey: []byte(cfg.Secret),
+		})
+	}
+
+	var keys []*SigningKey
+	for _, entry := range cfg.Keys {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid JWT_KEYS entry %q, expected kid:alg:path", entry)
+		}
+
+		data, err := os.ReadFile(parts[2])
+		if err != nil {
+			return fmt.Errorf("could not read signing key %s: %w", parts[0], err)
+		}
+
+		key, err := ParseSigningKey(parts[0], parts[1], data)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	activeID := cfg.ActiveKID
+	if activeID == "" {
+		activeID = keys[0].ID
+	}
+	return SetSigningKeys(activeID, keys...)
+}
+
+// ParseSigningKey builds a key from an HMAC secret or a PEM encoded RSA/Ed25519 key.
+// Public keys produce verification-only keys.
+func ParseSigningKey(kid, alg string, data []byte) (*SigningKey, error) {
+	method := jwt.GetSigningMethod(alg)
+	key := &SigningKey{ID: kid, Method: method}
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		secret := bytes.TrimSpace(data)
+		key.SignKey, key.VerifyKey = secret, secret
+
+	case *jwt.SigningMethodRSA:
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			key.SignKey, key.VerifyKey = private, &private.PublicKey
+			break
+		}
+		public, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", kid, err)
+		}
+		key.VerifyKey = public
+
+	case *SigningMethodEdDSA:
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("signing key %s: %w", kid, ErrInvalidKeyPEM)
+		}
+		if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			private, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("signing key %s: not an Ed25519 private key", kid)
+			}
+			key.SignKey, key.VerifyKey = private, private.Public().(ed25519.PublicKey)
+			break
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", kid, err)
+		}
+		public, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s: not an Ed25519 public key", kid)
+		}
+		key.VerifyKey = public
+
+	default:
+		return nil, fmt.Errorf("signing key %s: unsupported algorithm %q", kid, alg)
+	}
+
+	return key, nil
+}
+
+// SignToken signs the claims with the active key and sets the kid header
+func SignToken(claims jwt.Claims) (string, error) {
+	signingKeysMu.RLock()
+	key, ok := signingKeys[activeKeyID]
+	signingKeysMu.RUnlock()
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.SignKey)
+}
+
+// verificationKey resolves the kid header of a token to one of the configured keys
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	signingKeysMu.RLock()
+	key, ok := signingKeys[kid]
+	signingKeysMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+
+	// Reject tokens whose algorithm does not match the key, e.g. HS256 signed with an RSA public key
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidSigningMethod
+	}
+	return key.VerifyKey, nil
+}
+
+// JWKS returns the public signing keys, HMAC secrets are never published
+func JWKS() []JWK {
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+
+	keys := []JWK{}
+	for _, key := range signingKeys {
+		switch public := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
@@ -0,0 +1,48 @@
+package utils_test
+
+import (
+	"backend/utils"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestJWKPublicKeyEC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, 32))) }
+
+	tests := []struct {
+		name    string
+		jwk     utils.JWK
+		wantErr bool
+	}{
+		{name: "point on the curve", jwk: utils.JWK{Kty: "EC", Crv: "P-256", X: encode(key.X), Y: encode(key.Y)}},
+		{name: "point off the curve", jwk: utils.JWK{Kty: "EC", Crv: "P-256", X: encode(key.X), Y: encode(new(big.Int).Add(key.Y, big.NewInt(1)))}, wantErr: true},
+		{name: "coordinates of another curve", jwk: utils.JWK{Kty: "EC", Crv: "P-384", X: encode(key.X), Y: encode(key.Y)}, wantErr: true},
+		{name: "unsupported curve", jwk: utils.JWK{Kty: "EC", Crv: "secp256k1", X: encode(key.X), Y: encode(key.Y)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publicKey, err := tt.jwk.PublicKey()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("key accepted, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.PublicKey.Equal(publicKey) {
+				t.Fatal("decoded key differs from the original")
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TokenStore persists active tokens keyed by TokenKey
+type TokenStore interface {
+	Store(key string, info TokenInfo) error
+	Load(key string) (TokenInfo, error)
+	Delete(key string) error
+	DeleteUser(userID uint) (int, error)     // Removes every token of the user
+	DeleteFamily(family string) (int, error) // Removes every token of a refresh token family or session
+	PurgeExpired(now time.Time) (int, error)
+}
+
+// tokenStore is the store used by the token helpers, in-memory by default
+var tokenStore TokenStore = NewMemoryTokenStore()
+
+// SetTokenStore replaces the store used to track active tokens
+func SetTokenStore(store TokenStore) {
+	tokenStore = store
+}
+
+// tokenStoreIn returns the token store working inside the transaction tx when the tokens are kept
+// in the database, so they are deleted together with the rows they belong to
+func tokenStoreIn(tx *gorm.DB) TokenStore {
+	if _, ok := tokenStore.(*DatabaseTokenStore); ok {
+		return NewDatabaseTokenStore(tx)
+	}
+	return tokenStore
+}
+
+// GetTokenStore returns the store used to track active tokens
+func GetTokenStore() TokenStore {
+	return tokenStore
+}
+
+// tokenStoreProbeKey is read by CheckTokenStore, it is never stored
+const tokenStoreProbeKey = "readiness-probe"
+
+// CheckTokenStore names the store tracking active tokens, as in the token_store setting,
+// and reports whether it can be read
+func CheckTokenStore() (string, error) {
+	switch store := tokenStore.(type) {
+	case *DatabaseTokenStore:
+		_, err := store.Load(tokenStoreProbeKey)
+		if errors.Is(err, ErrTokenNotFound) {
+			err = nil
+		}
+		return "database", err
+	case *MemoryTokenStore:
+		return "memory", nil
+	default:
+		return "custom", nil
+	}
+}
+
+// TokenKey derives the storage key for a token so raw JWTs are never persisted
+func TokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryTokenStore keeps active tokens in process memory
+type MemoryTokenStore struct {
+	tokens sync.Map
+}
+
+// NewMemoryTokenStore creates an empty in-memory token store
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+// Store saves the token info under the given key
+func (s *MemoryTokenStore) Store(key string, info TokenInfo) error {
+	s.tokens.Store(key, info)
+	return nil
+}
+
+// Load returns the token info for the given key, expired tokens are not found
+func (s *MemoryTokenStore) Load(key string) (TokenInfo, error) {
+	value, ok := s.tokens.Load(key)
+	if !ok || !value.(TokenInfo).Expiration.After(time.Now()) {
+		return TokenInfo{}, ErrTokenNotFound
+	}
+	return value.(TokenInfo), nil
+}
+
+// Delete removes the given key, returning ErrTokenNotFound if it was absent
+func (s *MemoryTokenStore) Delete(key string) error {
+	if _, loaded := s.tokens.LoadAndDelete(key); !loaded {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// DeleteUser removes every token of the user
+func (s *MemoryTokenStore) DeleteUser(userID uint) (int, error) {
+	return s.deleteWhere(func(info TokenInfo) bool { return info.UserID == userID }), nil
+}
+
+// DeleteFamily removes every token issued for the refresh token family or session
+func (s *MemoryTokenStore) DeleteFamily(family string) (int, error) {
+	if family == "" {
+		return 0, nil
+	}
+	return s.deleteWhere(func(info TokenInfo) bool { return info.Family == family }), nil
+}
+
+// deleteWhere removes the tokens matching fn, scanning the whole map is fine in process memory
+func (s *MemoryTokenStore) deleteWhere(fn func(info TokenInfo) bool) int {
+	deleted := 0
+	s.tokens.Range(func(key, value interface{}) bool {
+		if fn(value.(TokenInfo)) {
+			s.tokens.Delete(key)
+			deleted++
+		}
+		return true // Continue iteration
+	})
+	return deleted
+}
+
+// PurgeExpired removes every token that expired before now
+func (s *MemoryTokenStore) PurgeExpired(now time.Time) (int, error) {
+	purged := 0
+	s.tokens.Range(func(key, value interface{}) bool {
+		if value.(TokenInfo).Expiration.Before(now) {
+			s.tokens.Delete(key)
+			purged++
+		}
+		return true // Continue iteration
+	})
+	return purged, nil
+}
@@ -0,0 +1,77 @@
+package utils_test
+
+import (
+	"backend/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) utils.TokenStore{
+		"memory":   func(t *testing.T) utils.TokenStore { return utils.NewMemoryTokenStore() },
+		"database": func(t *testing.T) utils.TokenStore { return utils.NewDatabaseTokenStore(newTestDB(t)) },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			live := time.Now().Add(time.Hour)
+			tokens := map[string]utils.TokenInfo{
+				"user1-a":  {UserID: 1, Family: "a", Expiration: live},
+				"user1-a2": {UserID: 1, Family: "a", Expiration: live},
+				"user1-b":  {UserID: 1, Family: "b", Expiration: live},
+				"user2-c":  {UserID: 2, Family: "c", Expiration: live},
+				"user2":    {UserID: 2, Expiration: live},
+				"expired":  {UserID: 3, Expiration: time.Now().Add(-time.Minute)},
+			}
+			for key, info := range tokens {
+				if err := store.Store(key, info); err != nil {
+					t.Fatal(err)
+				}
+			}
+			remaining := func(want ...string) {
+				t.Helper()
+				for key := range tokens {
+					_, err := store.Load(key)
+					found := err == nil
+					wanted := false
+					for _, w := range want {
+						wanted = wanted || w == key
+					}
+					if found != wanted {
+						t.Errorf("token %s found %v, want %v (%v)", key, found, wanted, err)
+					}
+				}
+			}
+
+			// Expired tokens are never loaded
+			remaining("user1-a", "user1-a2", "user1-b", "user2-c", "user2")
+
+			if deleted, err := store.DeleteFamily("a"); err != nil || deleted != 2 {
+				t.Fatalf("deleted %d tokens of family a: %v", deleted, err)
+			}
+			remaining("user1-b", "user2-c", "user2")
+
+			// An empty family never matches the tokens issued without one
+			if deleted, err := store.DeleteFamily(""); err != nil || deleted != 0 {
+				t.Fatalf("deleted %d tokens without family: %v", deleted, err)
+			}
+
+			if deleted, err := store.DeleteUser(2); err != nil || deleted != 2 {
+				t.Fatalf("deleted %d tokens of user 2: %v", deleted, err)
+			}
+			remaining("user1-b")
+
+			if purged, err := store.PurgeExpired(time.Now()); err != nil || purged != 1 {
+				t.Fatalf("purged %d tokens: %v", purged, err)
+			}
+			if err := store.Delete("user1-b"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete("user1-b"); !errors.Is(err, utils.ErrTokenNotFound) {
+				t.Fatalf("deleting a missing token returned %v", err)
+			}
+		})
+	}
+}
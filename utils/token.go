@@ -1,9 +1,9 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"log"
-	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -14,41 +14,58 @@ var (
 	ErrInvalidSigningMethod = errors.New("invalid signing method")
 	ErrInvalidToken         = errors.New("invalid token")
 	ErrTokenNotFound        = errors.New("token not found")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrTokenReused          = errors.New("token reuse detected")
 )
 
+// TypeClaim names the kind of a signed token. Each parser accepts only its own type, so a
+// token issued for one purpose cannot be presented as another.
+const TypeClaim = "typ"
+
+// AccessTokenType is the TypeClaim of access tokens
+const AccessTokenType = "access"
+
+// Token lifetimes, see SetTokenLifetimes
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// SetTokenLifetimes replaces the default access and refresh token lifetimes
+func SetTokenLifetimes(access, refresh time.Duration) {
+	AccessTokenTTL = access
+	RefreshTokenTTL = refresh
+}
+
 // TokenInfo holds information about the token and its expiration time
 type TokenInfo struct {
-	Expiration time.Time
+	UserID     uint      `json:"user_id"`
+	Family     string    `json:"family,omitempty"` // Refresh token family the token was issued for
+	Expiration time.Time `json:"expiration"`
 }
 
-// ActiveTokens to manage active tokens
-var activeTokens = sync.Map{}
-
 // ValidateToken validates the token and returns claims if valid
 func ValidateToken(token string) (jwt.MapClaims, error) {
-	// Check if the token is blacklisted
-	if info, ok := activeTokens.Load(token); !ok {
-		return nil, ErrTokenNotFound
-	} else if info.(TokenInfo).Expiration.Before(time.Now()) {
-		activeTokens.Delete(token)
+	// Check if the token is still active
+	key := TokenKey(token)
+	info, err := tokenStore.Load(key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Expiration.Before(time.Now()) {
+		tokenStore.Delete(key)
 		return nil, ErrTokenNotFound
 	}
 
-	// Parse the token
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidSigningMethod
-		}
-		return []byte("your_secret_key"), nil // Replace with your secret key
-	})
+	// Parse the token, resolving its kid header to a configured key
+	parsedToken, err := jwt.Parse(token, verificationKey)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if the token is valid and extract claims
-	if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok && parsedToken.Valid {
+	// Check if the token is a valid access token and extract claims
+	if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok && parsedToken.Valid && claims[TypeClaim] == AccessTokenType {
 		return claims, nil
 	}
 
@@ -57,64 +74,85 @@ func ValidateToken(token string) (jwt.MapClaims, error) {
 
 // GenerateJWT creates a new JWT token, removing the current token if provided
 func GenerateJWT(userID uint, currentToken string) (string, error) {
-	// If a current token is provided, remove it from the active tokens map
+	// If a current token is provided, remove it from the token store
 	if currentToken != "" {
-		activeTokens.Delete(currentToken)
+		tokenStore.Delete(TokenKey(currentToken))
 	}
 
+	return generateAccessToken(userID, "", nil)
+}
+
+// generateAccessToken signs a short-lived access token with any extra claims and records it in the token store
+func generateAccessToken(userID uint, family string, extra jwt.MapClaims) (string, error) {
+	expiration := time.Now().Add(AccessTokenTTL)
+
 	// Define the token claims, including a unique claim
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Token expires in 60 seconds
-		"iat":     time.Now().Unix(),                     // Issued at
+		"exp":     expiration.Unix(),
+		"iat":     time.Now().Unix(),           // Issued at
+		"jti":     GenerateVerificationToken(), // Keeps tokens issued in the same second distinct
 	}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	claims[TypeClaim] = AccessTokenType
 
-	// Create a new token object
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Replace "your_secret_key" with a strong secret key
-	secretKey := []byte("your_secret_key")
-
-	// Sign the token with the secret key
-	signedToken, err := token.SignedString(secretKey)
+	// Sign the token with the active signing key
+	signedToken, err := SignToken(claims)
 	if err != nil {
 		return "", err
 	}
 
-	// Store the new token in the active tokens map with its expiration time
-	activeTokens.Store(signedToken, TokenInfo{Expiration: time.Now().Add(time.Hour * 24)})
+	// Store the new token in the token store with its expiration time
+	if err := tokenStore.Store(TokenKey(signedToken), TokenInfo{UserID: userID, Family: family, Expiration: expiration}); err != nil {
+		return "", err
+	}
 
 	return signedToken, nil
 }
 
-// DeleteToken removes a token from the active tokens map
+// LookupToken returns the stored info of an active token
+func LookupToken(token string) (TokenInfo, error) {
+	return tokenStore.Load(TokenKey(token))
+}
+
+// DeleteToken removes a token from the token store
 func DeleteToken(token string) error {
-	_, loaded := activeTokens.LoadAndDelete(token)
-	if !loaded {
-		return ErrTokenNotFound
+	return tokenStore.Delete(TokenKey(token))
+}
+
+// DeleteUserTokens removes every active token issued to the user
+func DeleteUserTokens(userID uint) error {
+	deleted, err := tokenStore.DeleteUser(userID)
+	if err != nil {
+		return err
 	}
-	log.Printf("Token %s has been removed from active tokens.", token)
+
+	log.Printf("%d active tokens of user %d have been removed.", deleted, userID)
 	return nil
 }
 
-// CleanupExpiredTokens periodically removes expired tokens from activeTokens
-func CleanupExpiredTokens() {
+// CleanupExpiredTokens periodically removes expired tokens from the token store until ctx is cancelled
+func CleanupExpiredTokens(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Second) // Adjust the interval as needed
+	defer ticker.Stop()
+
 	for {
-		time.Sleep(10 * time.Second) // Adjust the interval as needed
-		now := time.Now()
-
-		activeTokens.Range(func(key, value interface{}) bool {
-			tokenInfo := value.(TokenInfo)
-			if tokenInfo.Expiration.Before(now) {
-				activeTokens.Delete(key)
-				log.Printf("Token %s has been removed from active tokens due to expiration.", key)
-			}
-			return true // Continue iteration
-		})
-	}
-}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-// StartCleanupRoutine starts the cleanup routine in a separate goroutine
-func StartCleanupRoutine() {
-	go CleanupExpiredTokens()
+		purged, err := tokenStore.PurgeExpired(time.Now())
+		if err != nil {
+			log.Printf("Could not purge expired tokens: %v", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("%d tokens have been removed from active tokens due to expiration.", purged)
+		}
+		purgeExpiredWebSessions(time.Now())
+	}
 }
@@ -0,0 +1,95 @@
+package utils_test
+
+import (
+	"backend/utils"
+	"testing"
+)
+
+func TestTokenTypes(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "user@example.com")
+
+	pair, err := utils.IssueTokenPair(db, user.ID, false, utils.Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interim, err := utils.IssueInterimToken(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := utils.ValidateToken(pair.AccessToken); err != nil {
+		t.Fatalf("access token rejected: %v", err)
+	}
+	if _, err := utils.ValidateToken(interim); err == nil {
+		t.Fatal("interim token accepted as an access token")
+	}
+	if _, _, err := utils.ParseInterimToken(interim); err != nil {
+		t.Fatalf("interim token rejected: %v", err)
+	}
+	if _, _, err := utils.ParseInterimToken(pair.AccessToken); err == nil {
+		t.Fatal("access token accepted as an interim token")
+	}
+}
+
+func TestTokenMFAClaim(t *testing.T) {
+	tests := []struct {
+		name string
+		mfa  bool
+	}{
+		{name: "password login", mfa: false},
+		{name: "two-factor login", mfa: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user := newTestUser(t, db, "user@example.com")
+
+			pair, err := utils.IssueTokenPair(db, user.ID, tt.mfa, utils.Device{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The second factor of the login is kept when the refresh token is rotated
+			rotated, err := utils.RotateRefreshToken(db, pair.RefreshToken, utils.Device{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, token := range []string{pair.AccessToken, rotated.AccessToken} {
+				claims, err := utils.ValidateToken(token)
+				if err != nil {
+					t.Fatal(err)
+				}
+				principal, err := utils.PrincipalFromClaims(claims)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if principal.MFA != tt.mfa {
+					t.Fatalf("got mfa %v, want %v", principal.MFA, tt.mfa)
+				}
+			}
+		})
+	}
+}
+
+func TestImpersonationTokenHasNoMFA(t *testing.T) {
+	db := newTestDB(t)
+	admin := newTestUser(t, db, "admin@example.com")
+	user := newTestUser(t, db, "user@example.com")
+
+	token, err := utils.IssueImpersonationToken(db, admin.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := utils.ValidateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mfa, _ := claims["mfa"].(bool); mfa {
+		t.Fatal("impersonation token claims two-factor authentication")
+	}
+	if claims[utils.TypeClaim] != utils.AccessTokenType {
+		t.Fatalf("got typ %v, want %s", claims[utils.TypeClaim], utils.AccessTokenType)
+	}
+}
@@ -0,0 +1,42 @@
+package utils_test
+
+import (
+	"backend/utils"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		now      int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "RFC 6238 at 59s", secret: rfcSecret, code: "287082", now: 59, wantStep: 1, wantOK: true},
+		{name: "RFC 6238 at 1111111109s", secret: rfcSecret, code: "081804", now: 1111111109, wantStep: 37037036, wantOK: true},
+		{name: "RFC 6238 at 1234567890s", secret: rfcSecret, code: "005924", now: 1234567890, wantStep: 41152263, wantOK: true},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: "287082", now: 59, wantStep: 1, wantOK: true},
+		{name: "previous step within drift", secret: rfcSecret, code: "287082", now: 59 + 30, wantStep: 1, wantOK: true},
+		{name: "next step within drift", secret: rfcSecret, code: "287082", now: 59 - 30, wantStep: 1, wantOK: true},
+		{name: "two steps late", secret: rfcSecret, code: "287082", now: 59 + 60},
+		{name: "two steps early", secret: rfcSecret, code: "005924", now: 1234567890 - 60},
+		{name: "wrong code", secret: rfcSecret, code: "287083", now: 59},
+		{name: "short code", secret: rfcSecret, code: "28708", now: 59},
+		{name: "invalid secret", secret: "not base32!", code: "287082", now: 59},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := utils.ValidateTOTP(tt.secret, tt.code, time.Unix(tt.now, 0))
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Fatalf("got step %d ok %v, want step %d ok %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"backend/model"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+// Interim tokens bridge the password and second factor steps of a login
+const (
+	interimTokenTTL   = 5 * time.Minute
+	interimTokenType  = "2fa"
+	recoveryCodeCount = 10
+)
+
+// TwoFactorEnabled reports whether the user has confirmed TOTP enrollment
+func TwoFactorEnabled(db *gorm.DB, userID uint) (bool, error) {
+	var count int64
+	if err := db.Model(&model.TwoFactor{}).Where("user_id = ? AND enabled = ?", userID, true).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// VerifyTwoFactorCode accepts a TOTP code or an unused recovery code, consuming either
+func VerifyTwoFactorCode(db *gorm.DB, twoFactor *model.TwoFactor, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if step, ok := ValidateTOTP(twoFactor.Secret, code, time.Now()); ok {
+		// Only accept each step once so an observed code cannot be replayed
+		result := db.Model(&model.TwoFactor{}).
+			Where("id = ? AND last_used_step < ?", twoFactor.ID, step).
+			Update("last_used_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected > 0, nil
+	}
+
+	result := db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", twoFactor.UserID, TokenKey(strings.ToLower(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GenerateRecoveryCodes replaces the user's recovery codes and returns the new plain codes
+func GenerateRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for i := 0; i < recoveryCodeCount; i++ {
+			token := GenerateVerificationToken()
+			code := token[:5] + "-" + token[5:10]
+			if err := tx.Create(&model.RecoveryCode{UserID: userID, CodeHash: TokenKey(code)}).Error; err != nil {
+				return err
+			}
+			codes = append(codes, code)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// IssueInterimToken signs a short-lived token proving the password step of a login succeeded.
+// It is kept out of the active tokens so it can never be used as an access token.
+func IssueInterimToken(userID uint) (string, error) {
+	jti := GenerateVerificationToken()
+	expiration := time.Now().Add(interimTokenTTL)
+
+	token, err := SignToken(jwt.MapClaims{
+		"user_id": userID,
+		TypeClaim: interimTokenType,
+		"exp":     expiration.Unix(),
+		"iat":     time.Now().Unix(),
+		"jti":     jti,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := tokenStore.Store(interimTokenKey(jti), TokenInfo{UserID: userID, Expiration: expiration}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ParseInterimToken returns the user and token ID of a valid, unused interim token
+func ParseInterimToken(token string) (uint, string, error) {
+	parsedToken, err := jwt.Parse(token, verificationKey)
+	if err != nil {
+		return 0, "", err
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || !parsedToken.Valid || claims[TypeClaim] != interimTokenType {
+		return 0, "", ErrInvalidToken
+	}
+
+	jti, _ := claims["jti"].(string)
+	info, err := tokenStore.Load(interimTokenKey(jti))
+	if err != nil {
+		return 0, "", err
+	}
+	if info.Expiration.Before(time.Now()) {
+		return 0, "", ErrTokenExpired
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok || uint(userID) != info.UserID {
+		return 0, "", ErrInvalidToken
+	}
+	return info.UserID, jti, nil
+}
+
+// ConsumeInterimToken marks the interim token as used, failing if it was used concurrently
+func ConsumeInterimToken(jti string) error {
+	if err := tokenStore.Delete(interimTokenKey(jti)); err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			return ErrTokenReused
+		}
+		return err
+	}
+	return nil
+}
+
+// interimTokenKey keeps interim tokens apart from access token keys in the token store
+func interimTokenKey(jti string) string {
+	return "interim:" + jti
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"backend/config"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+	"gorm.io/gorm"
+)
+
+// Cookie and header names of browser sessions
+const (
+	WebSessionCookie = "session_id"
+	CSRFCookie       = "csrf_token" // Readable by the page, echoed back in CSRFHeader or CSRFFormField
+	CSRFHeader       = "X-CSRF-Token"
+	CSRFFormField    = "_csrf"
+	sessionUserKey   = "user_id"
+	sessionCSRFKey   = "csrf_token"
+	sessionMFAKey    = "mfa"
+)
+
+// Define custom error messages
+var (
+	ErrInvalidWebSession = errors.New("invalid or expired session")
+	ErrInvalidCSRFToken  = errors.New("missing or invalid CSRF token")
+)
+
+// WebSessionConfig controls the cookie sessions used by server-rendered pages
+type WebSessionConfig struct {
+	TTL            time.Duration // How long a session lasts after login
+	CookieSecure   bool          // Only send the cookies over HTTPS
+	CookieSameSite string        // SameSite attribute of the session cookie, Lax or Strict
+}
+
+// webSession is the active configuration, see SetWebSessionConfig
+var webSession = WebSessionConfig{
+	TTL:            12 * time.Hour,
+	CookieSecure:   true,
+	CookieSameSite: "Lax",
+}
+
+// ExpiringStorage is a fiber.Storage that can also remove its expired entries, such as the
+// database storage
+type ExpiringStorage interface {
+	fiber.Storage
+	PurgeExpired(now time.Time) (int, error)
+}
+
+// webSessionStorage keeps the session data, nil keeps it in memory
+var webSessionStorage fiber.Storage
+
+// webSessions is the session store built from the configuration and storage
+var webSessions = newWebSessionStore()
+
+// SetWebSessionConfig replaces the defaults with the validated configuration
+func SetWebSessionConfig(cfg config.SessionConfig) {
+	webSession = WebSessionConfig{
+		TTL:            cfg.TTL,
+		CookieSecure:   cfg.CookieSecure,
+		CookieSameSite: strings.ToLower(cfg.CookieSameSite),
+	}
+	webSessions = newWebSessionStore()
+}
+
+// SetWebSessionStorage replaces the storage browser sessions are kept in
+func SetWebSessionStorage(storage fiber.Storage) {
+	webSessionStorage = storage
+	webSessions = newWebSessionStore()
+}
+
+// purgeExpiredWebSessions removes expired sessions from a storage that does not expire them itself
+func purgeExpiredWebSessions(now time.Time) {
+	storage, ok := webSessionStorage.(ExpiringStorage)
+	if !ok {
+		return
+	}
+	if _, err := storage.PurgeExpired(now); err != nil {
+		log.Printf("Could not purge expired sessions: %v", err)
+	}
+}
+
+// newWebSessionStore creates the session store with HttpOnly cookies
+func newWebSessionStore() *session.Store {
+	return session.New(session.Config{
+		Expiration:     webSession.TTL,
+		Storage:        webSessionStorage,
+		KeyLookup:      "cookie:" + WebSessionCookie,
+		CookiePath:     "/",
+		CookieSecure:   webSession.CookieSecure,
+		CookieHTTPOnly: true,
+		CookieSameSite: webSession.CookieSameSite,
+		KeyGenerator:   func() string { return randomURLSafe(32) },
+	})
+}
+
+// webSessionKey is the active token entry of a session, so logging out everywhere ends it too
+func webSessionKey(sessionID string) string {
+	return TokenKey("session:" + sessionID)
+}
+
+// StartWebSession logs the user in on a new session cookie and returns its CSRF token. mfa is
+// set when the login passed two-factor authentication. The login is recorded as a session of
+// the device, so it is listed and revoked with the user's other sessions.
+func StartWebSession(c fiber.Ctx, db *gorm.DB, userID uint, mfa bool, device Device) (string, error) {
+	sess, err := webSessions.Get(c)
+	if err != nil {
+		return "", err
+	}
+
+	// A new ID on every login prevents session fixation
+	if err := sess.Regenerate(); err != nil {
+		return "", err
+	}
+
+	csrfToken := randomURLSafe(32)
+	sess.Set(sessionUserKey, userID)
+	sess.Set(sessionCSRFKey, csrfToken)
+	sess.Set(sessionMFAKey, mfa)
+	sessionID := sess.ID()
+	if err := sess.Save(); err != nil {
+		return "", err
+	}
+
+	family := GenerateVerificationToken()
+	if err := createSession(db, userID, family, device); err != nil {
+		return "", err
+	}
+	if err := tokenStore.Store(webSessionKey(sessionID), TokenInfo{
+		UserID:     userID,
+		Family:     family,
+		Expiration: time.Now().Add(webSession.TTL),
+	}); err != nil {
+		return "", err
+	}
+
+	setCSRFCookie(c, csrfToken, time.Now().Add(webSession.TTL))
+	return csrfToken, nil
+}
+
+// WebSessionPrincipal loads the principal of the request's session cookie and the session's CSRF token
+func WebSessionPrincipal(c fiber.Ctx, db *gorm.DB) (*Principal, string, error) {
+	sessionID := c.Cookies(WebSessionCookie)
+	if sessionID == "" {
+		return nil, "", ErrInvalidWebSession
+	}
+
+	// Sessions removed from the active tokens were logged out
+	info, err := tokenStore.Load(webSessionKey(sessionID))
+	if err != nil || info.Expiration.Before(time.Now()) {
+		return nil, "", ErrInvalidWebSession
+	}
+
+	sess, err := webSessions.Get(c)
+	if err != nil {
+		return nil, "", err
+	}
+	userID, ok := sess.Get(sessionUserKey).(uint)
+	csrfToken, _ := sess.Get(sessionCSRFKey).(string)
+	mfa, _ := sess.Get(sessionMFAKey).(bool)
+	if !ok || userID != info.UserID || csrfToken == "" {
+		return nil, "", ErrInvalidWebSession
+	}
+
+	// Roles are loaded on every request so changes apply to running sessions
+	claims, err := UserClaims(db, userID, mfa)
+	if err != nil {
+		return nil, "", ErrInvalidWebSession
+	}
+	return &Principal{
+		Type:        PrincipalUser,
+		UserID:      userID,
+		Roles:       stringsClaim(claims, "roles"),
+		Permissions: stringsClaim(claims, "permissions"),
+		MFA:         mfa,
+	}, csrfToken, nil
+}
+
+// CheckCSRF enforces the double-submit token on state-changing requests: the CSRF cookie and
+// the submitted header or form field must both match the session's token
+func CheckCSRF(c fiber.Ctx, sessionToken string) error {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return nil
+	}
+
+	submitted := c.Get(CSRFHeader)
+	if submitted == "" {
+		submitted = c.FormValue(CSRFFormField)
+	}
+	cookie := c.Cookies(CSRFCookie)
+	if submitted == "" || cookie == "" ||
+		subtle.ConstantTimeCompare([]byte(submitted), []byte(cookie)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(cookie), []byte(sessionToken)) != 1 {
+		return ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+// WebSessionFamily returns the session family of the request's session cookie, empty without one
+func WebSessionFamily(c fiber.Ctx) string {
+	sessionID := c.Cookies(WebSessionCookie)
+	if sessionID == "" {
+		return ""
+	}
+	info, err := tokenStore.Load(webSessionKey(sessionID))
+	if err != nil {
+		return ""
+	}
+	return info.Family
+}
+
+// EndWebSession logs out the request's session and clears its cookies
+func EndWebSession(c fiber.Ctx, db *gorm.DB) error {
+	sessionID := c.Cookies(WebSessionCookie)
+	if family := WebSessionFamily(c); family != "" {
+		if err := RevokeTokenFamily(db, family); err != nil {
+			return err
+		}
+	} else if sessionID != "" {
+		tokenStore.Delete(webSessionKey(sessionID))
+	}
+
+	sess, err := webSessions.Get(c)
+	if err != nil {
+		return err
+	}
+	if err := sess.Destroy(); err != nil {
+		return err
+	}
+
+	setCSRFCookie(c, "", time.Unix(0, 0))
+	return nil
+}
+
+// setCSRFCookie sets the cookie pages read the CSRF token from, it is not HttpOnly on purpose
+func setCSRFCookie(c fiber.Ctx, token string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   webSession.CookieSecure,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
@@ -0,0 +1,92 @@
+package utils_test
+
+import (
+	"backend/utils"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+func TestWebSessionsAreListedAndRevoked(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(db *gorm.DB, userID uint, sessionID uint) error
+	}{
+		{
+			name: "revoke the session",
+			revoke: func(db *gorm.DB, userID uint, sessionID uint) error {
+				return utils.RevokeSession(db, userID, sessionID)
+			},
+		},
+		{
+			name: "revoke the other sessions",
+			revoke: func(db *gorm.DB, userID uint, sessionID uint) error {
+				_, err := utils.RevokeOtherSessions(db, userID, "")
+				return err
+			},
+		},
+		{
+			name:   "log out everywhere",
+			revoke: func(db *gorm.DB, userID uint, sessionID uint) error { return utils.RevokeUserTokens(db, userID) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user := newTestUser(t, db, "user@example.com")
+
+			app := fiber.New()
+			app.Post("/login", func(c fiber.Ctx) error {
+				_, err := utils.StartWebSession(c, db, user.ID, false, utils.Device{UserAgent: "browser"})
+				return err
+			})
+			app.Get("/me", func(c fiber.Ctx) error {
+				if _, _, err := utils.WebSessionPrincipal(c, db); err != nil {
+					return fiber.ErrUnauthorized
+				}
+				return nil
+			})
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/login", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cookie string
+			for _, c := range resp.Cookies() {
+				if c.Name == utils.WebSessionCookie {
+					cookie = c.Name + "=" + c.Value
+				}
+			}
+			me := func() int {
+				req := httptest.NewRequest(fiber.MethodGet, "/me", nil)
+				req.Header.Set("Cookie", cookie)
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return resp.StatusCode
+			}
+			if status := me(); status != fiber.StatusOK {
+				t.Fatalf("session rejected with %d before revoking", status)
+			}
+
+			sessions, err := utils.ListSessions(db, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sessions) != 1 || sessions[0].UserAgent != "browser" {
+				t.Fatalf("got sessions %+v, want the web session", sessions)
+			}
+
+			if err := tt.revoke(db, user.ID, sessions[0].ID); err != nil {
+				t.Fatal(err)
+			}
+			if status := me(); status != fiber.StatusUnauthorized {
+				t.Fatalf("revoked session answered with %d", status)
+			}
+		})
+	}
+}
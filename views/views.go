@@ -0,0 +1,9 @@
+// Package views holds the HTML pages served by the application
+package views
+
+import "embed"
+
+// Files are the embedded pages
+//
+//go:embed *.html
+var Files embed.FS